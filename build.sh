@@ -1,3 +1,3 @@
-docker run --rm -v "$PWD":/app -w /app -e GOOS=linux -e GOARCH=arm64 -e CGO_ENABLED=1 golang:1.22 go build -o ./finaltask ./cmd/final-project/
+docker run --rm -v "$PWD":/app -w /app -e GOOS=linux -e GOARCH=arm64 -e CGO_ENABLED=1 golang:1.22 go build -tags sqlite_fts5 -o ./finaltask ./cmd/final-project/
 docker build --no-cache --tag finaltask:v1 .
 rm ./finaltask
\ No newline at end of file
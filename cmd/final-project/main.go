@@ -1,13 +1,18 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	//the time zones of the tasks are available even if the system has no tzdata
+	_ "time/tzdata"
 
 	"github.com/OlegShamkeev/go_final_project/internal/api"
 	"github.com/OlegShamkeev/go_final_project/internal/config"
+	"github.com/OlegShamkeev/go_final_project/internal/reminder"
 	"github.com/OlegShamkeev/go_final_project/internal/storage"
+	"github.com/OlegShamkeev/go_final_project/internal/user"
 
 	"github.com/caarlos0/env"
 	"github.com/go-chi/chi/v5"
@@ -15,38 +20,162 @@ import (
 
 var cfg config.Config
 
+type route struct {
+	method  string
+	pattern string
+	// role is the lowest role permitted to call the route, the empty one makes the route public
+	role    string
+	handler http.HandlerFunc
+}
+
+// routes of the API with their permissions, roles are checked only if authentication is enabled by TODO_PASSWORD
+var routes = []route{
+	{http.MethodGet, "/api/nextdate", "", api.GetNextDate},
+	{http.MethodPost, "/api/signin", "", api.AuthAndGenerateToken},
+	{http.MethodPost, "/api/token/refresh", "", api.RefreshToken},
+	{http.MethodPost, "/api/signout", "", api.SignOut},
+	{http.MethodPost, "/api/register", "", api.Register},
+	{http.MethodGet, "/api/calendar.ics", "", api.GetCalendar},
+
+	{http.MethodGet, "/api/tasks", user.RoleViewer, api.GetTasks},
+	{http.MethodGet, "/api/task", user.RoleViewer, api.GetTask},
+	{http.MethodGet, "/api/task/history", user.RoleViewer, api.GetTaskHistory},
+	{http.MethodGet, "/api/task/{id}/items", user.RoleViewer, api.GetTaskItems},
+	{http.MethodGet, "/api/task/dependencies", user.RoleViewer, api.GetTaskDependencies},
+	{http.MethodGet, "/api/history", user.RoleViewer, api.GetHistory},
+	{http.MethodGet, "/api/trash", user.RoleViewer, api.GetTrash},
+	{http.MethodGet, "/api/tags", user.RoleViewer, api.GetTags},
+	{http.MethodGet, "/api/user", user.RoleViewer, api.GetCurrentUser},
+	{http.MethodGet, "/api/keys", user.RoleViewer, api.GetApiKeys},
+	{http.MethodGet, "/api/projects", user.RoleViewer, api.GetProjects},
+	{http.MethodGet, "/api/projects/members", user.RoleViewer, api.GetProjectMembers},
+
+	{http.MethodPost, "/api/task", user.RoleEditor, api.PostTask},
+	{http.MethodPut, "/api/task", user.RoleEditor, api.UpdateTask},
+	{http.MethodPost, "/api/task/done", user.RoleEditor, api.CheckDoneTask},
+	{http.MethodDelete, "/api/task", user.RoleEditor, api.DeleteTask},
+	{http.MethodPost, "/api/task/restore", user.RoleEditor, api.RestoreTask},
+	{http.MethodPost, "/api/tasks/batch", user.RoleEditor, api.BatchTasks},
+	{http.MethodPost, "/api/task/{id}/items", user.RoleEditor, api.AddTaskItem},
+	{http.MethodPut, "/api/task/{id}/items", user.RoleEditor, api.UpdateTaskItem},
+	{http.MethodDelete, "/api/task/{id}/items", user.RoleEditor, api.DeleteTaskItem},
+	{http.MethodPost, "/api/task/dependencies", user.RoleEditor, api.AddTaskDependency},
+	{http.MethodDelete, "/api/task/dependencies", user.RoleEditor, api.DeleteTaskDependency},
+	{http.MethodPost, "/api/import/ics", user.RoleEditor, api.ImportCalendar},
+	{http.MethodPost, "/api/projects", user.RoleEditor, api.CreateProject},
+	{http.MethodPut, "/api/projects", user.RoleEditor, api.UpdateProject},
+	{http.MethodDelete, "/api/projects", user.RoleEditor, api.DeleteProject},
+	{http.MethodPut, "/api/projects/members", user.RoleEditor, api.SetProjectMember},
+	{http.MethodDelete, "/api/projects/members", user.RoleEditor, api.DeleteProjectMember},
+	{http.MethodPut, "/api/user", user.RoleEditor, api.UpdateCurrentUser},
+	{http.MethodPost, "/api/keys", user.RoleEditor, api.CreateApiKey},
+	{http.MethodDelete, "/api/keys", user.RoleEditor, api.RevokeApiKey},
+
+	{http.MethodGet, "/api/users", user.RoleAdmin, api.GetUsers},
+	{http.MethodPut, "/api/users", user.RoleAdmin, api.UpdateUserRole},
+	{http.MethodGet, "/api/audit", user.RoleAdmin, api.GetAudit},
+}
+
+var migrateCmd = flag.String("migrate", "", "run database migrations and exit: up, down or status")
+var migrateSteps = flag.Int("steps", 0, "number of migrations to apply or roll back (0 - all pending for up, 1 for down)")
+var rotateKey = flag.Bool("rotate-jwt-key", false, "generate new JWT signing key, the current one is accepted during TODO_JWT_KEY_GRACE, and exit")
+
 func main() {
 	var err error
+	flag.Parse()
+
 	if err := env.Parse(&cfg); err != nil {
 		log.Fatalf("Error during parse enviroment variable(s) %s", err.Error())
 		return
 	}
 
+	if *rotateKey {
+		if err := api.RotateSigningKey(&cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	storage.NewStorage(&cfg)
 
-	db, err := storage.InitDB(cfg.DBPath)
+	repo, err := storage.New(&cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close()
+	defer repo.Close()
+
+	migrator, withMigrations := repo.(storage.Migrator)
+
+	if len(*migrateCmd) > 0 {
+		if !withMigrations {
+			log.Fatal("configured storage doesn't support migrations")
+		}
+		if err := runMigrations(migrator, *migrateCmd, *migrateSteps); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if withMigrations {
+		if err := migrator.MigrateUp(0); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := api.NewApi(&cfg, repo); err != nil {
+		log.Fatal(err)
+	}
 
-	api.NewApi(&cfg, &storage.Storage{Db: db})
+	go storage.RunTrashPurge(repo, cfg.TrashRetention, cfg.TrashPurgeInterval)
+	go storage.RunRevokedTokensPurge(repo, cfg.TrashPurgeInterval)
+
+	scheduler, err := reminder.NewScheduler(repo, &cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if scheduler.Enabled() {
+		go scheduler.Run(cfg.ReminderInterval)
+		go storage.RunDeliveriesPurge(repo, cfg.TrashPurgeInterval)
+	}
 
 	r := chi.NewRouter()
 
 	r.Handle("/*", http.FileServer(http.Dir(cfg.WebFolder)))
 
-	r.Get("/api/nextdate", api.GetNextDate)
-	r.Post("/api/task", api.Auth(api.PostTask))
-	r.Get("/api/tasks", api.Auth(api.GetTasks))
-	r.Get("/api/task", api.Auth(api.GetTask))
-	r.Put("/api/task", api.Auth(api.UpdateTask))
-	r.Post("/api/task/done", api.Auth(api.CheckDoneTask))
-	r.Delete("/api/task", api.Auth(api.DeleteTask))
-	r.Post("/api/signin", api.AuthAndGenerateToken)
+	for _, rt := range routes {
+		handler := rt.handler
+		if len(rt.role) > 0 {
+			handler = api.Auth(rt.role, handler)
+		}
+		r.Method(rt.method, rt.pattern, handler)
+	}
 
 	log.Printf("Starting web-server on port: %d\n", cfg.Port)
 	if err := http.ListenAndServe(fmt.Sprintf("0.0.0.0:%d", cfg.Port), r); err != nil {
 		log.Fatalf("Error starting web-server: %s", err.Error())
 	}
 }
+
+func runMigrations(migrator storage.Migrator, cmd string, steps int) error {
+	switch cmd {
+	case "up":
+		return migrator.MigrateUp(steps)
+	case "down":
+		return migrator.MigrateDown(steps)
+	case "status":
+		statuses, err := migrator.Migrations()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if len(s.AppliedAt) > 0 {
+				applied = "applied at " + s.AppliedAt
+			}
+			fmt.Printf("%d_%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", cmd)
+	}
+}
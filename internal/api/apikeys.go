@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/apikey"
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+)
+
+// createdApiKey is returned once on creation, later only the hint of the key is shown
+type createdApiKey struct {
+	apikey.Key
+	Secret string `json:"key"`
+}
+
+func withApiKey(r *http.Request, key *apikey.Key) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), apiKeyKey, key))
+}
+
+// requestApiKey returns the API key the request is authenticated by, nil for the token
+func requestApiKey(r *http.Request) *apikey.Key {
+	key, _ := r.Context().Value(apiKeyKey).(*apikey.Key)
+	return key
+}
+
+// authenticateApiKey checks the key, the status is returned for the failed check
+func authenticateApiKey(secret string) (*apikey.Key, *user.User, uint, error) {
+	key, err := store.GetApiKeyByHash(apikey.Hash(secret))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, http.StatusUnauthorized, fmt.Errorf("api key isn't valid")
+	}
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, err
+	}
+	if key.Expired(time.Now()) {
+		return nil, nil, http.StatusUnauthorized, fmt.Errorf("api key is expired")
+	}
+	u, err := store.GetUser(key.UserId)
+	if err != nil {
+		return nil, nil, http.StatusUnauthorized, fmt.Errorf("user of the api key isn't found")
+	}
+	return key, u, http.StatusOK, nil
+}
+
+// denyApiKey rejects the management of API keys by the request authenticated by an API key
+func denyApiKey(w http.ResponseWriter, r *http.Request) bool {
+	if len(cfg.Password) == 0 {
+		errorMessage(w, http.StatusForbidden, "authentication is disabled")
+		return true
+	}
+	if requestApiKey(r) != nil {
+		errorMessage(w, http.StatusForbidden, "api keys are managed only after sign in")
+		return true
+	}
+	return false
+}
+
+// GetApiKeys returns API keys of the user without the keys themselves
+func GetApiKeys(w http.ResponseWriter, r *http.Request) {
+	if denyApiKey(w, r) {
+		return
+	}
+	keys, err := userRepo(r).GetApiKeys()
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, &map[string]any{"keys": keys})
+}
+
+// CreateApiKey generates the key from the name, scope and optional expires_at of the body
+func CreateApiKey(w http.ResponseWriter, r *http.Request) {
+	audit := newAudit(r, actionKeyCreate, 0)
+	w = auditWriter(w, audit)
+	defer saveAudit(audit, nil)
+
+	if denyApiKey(w, r) {
+		return
+	}
+	var key apikey.Key
+	if err := json.NewDecoder(r.Body).Decode(&key); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if resultValidate := key.Validate(); resultValidate != "" {
+		errorMessage(w, http.StatusBadRequest, resultValidate)
+		return
+	}
+	//the key doesn't get more than the request creating it is granted
+	if key.Scope == apikey.ScopeWrite && !user.Permits(currentRole(r), user.RoleEditor) {
+		errorMessage(w, http.StatusForbidden, "write scope requires the editor role")
+		return
+	}
+	key.Grant(currentRole(r))
+
+	secret, err := apikey.Generate()
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	key.Assign(secret)
+	key.Id, err = userRepo(r).CreateApiKey(&key)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	//the key itself isn't recorded, only its hint
+	audit.Diff = auditDiff(nil, key)
+
+	writeJson(w, http.StatusCreated, &createdApiKey{Key: key, Secret: secret})
+}
+
+// RevokeApiKey deletes the key, requests with it are rejected right away
+func RevokeApiKey(w http.ResponseWriter, r *http.Request) {
+	audit := newAudit(r, actionKeyRevoke, 0)
+	w = auditWriter(w, audit)
+	defer saveAudit(audit, nil)
+
+	if denyApiKey(w, r) {
+		return
+	}
+	idInt, err := validateTaskID(r.URL.Query().Get("id"))
+	if err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := userRepo(r).DeleteApiKey(idInt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorMessage(w, http.StatusNotFound, "api key isn't found")
+			return
+		}
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	audit.Diff = auditDiff(map[string]any{"id": idInt}, nil)
+
+	writeJson(w, http.StatusOK, &map[string]any{})
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/storage"
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+)
+
+// actions of the audit entries, the operations on the tasks are recorded with the names of the operations of the batch
+const (
+	actionSignIn           = "signin"
+	actionDenied           = "denied"
+	actionImport           = "import"
+	actionRestore          = "restore"
+	actionItemCreate       = "item_create"
+	actionItemUpdate       = "item_update"
+	actionItemDelete       = "item_delete"
+	actionDependencyCreate = "dependency_create"
+	actionDependencyDelete = "dependency_delete"
+	actionProjectCreate    = "project_create"
+	actionProjectUpdate    = "project_update"
+	actionProjectDelete    = "project_delete"
+	actionMemberSet        = "member_set"
+	actionMemberDelete     = "member_delete"
+	actionUserRole         = "user_role"
+	actionKeyCreate        = "key_create"
+	actionKeyRevoke        = "key_revoke"
+)
+
+// auditResponse records the status and the error of the response to the audit entry
+type auditResponse struct {
+	http.ResponseWriter
+	entry *storage.AuditEntry
+}
+
+func (a *auditResponse) WriteHeader(status int) {
+	a.entry.Status = status
+	a.ResponseWriter.WriteHeader(status)
+}
+
+// auditWriter returns the writer recording the response of the handler to the entry,
+// the handler saves the entry by saveAudit with nil error when it returns
+func auditWriter(w http.ResponseWriter, e *storage.AuditEntry) http.ResponseWriter {
+	return &auditResponse{ResponseWriter: w, entry: e}
+}
+
+// newAudit returns the audit entry of the request made by the current user
+func newAudit(r *http.Request, action string, taskId int) *storage.AuditEntry {
+	u := currentUser(r)
+	remoteAddr, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteAddr = r.RemoteAddr
+	}
+	return &storage.AuditEntry{
+		CreatedAt:  time.Now().UTC().Format(time.RFC3339),
+		ActorId:    u.Id,
+		Actor:      u.Login,
+		RemoteAddr: remoteAddr,
+		Endpoint:   r.Method + " " + r.URL.Path,
+		Action:     action,
+		TaskId:     taskId,
+	}
+}
+
+// saveAudit records the entry with the outcome of the attempt, it doesn't fail the request
+func saveAudit(e *storage.AuditEntry, err error) {
+	var opErr *taskError
+	switch {
+	case err == nil && e.Status > 0:
+		//the outcome is recorded from the response by auditWriter
+	case err == nil && e.Action == opCreate:
+		e.Status = http.StatusCreated
+	case err == nil:
+		e.Status = http.StatusOK
+	case errors.As(err, &opErr):
+		e.Status, e.Error = int(opErr.status), opErr.message
+	default:
+		e.Status, e.Error = http.StatusInternalServerError, err.Error()
+	}
+	if err := store.AddAuditEntry(e); err != nil {
+		log.Printf("error during saving the audit entry: %s\n", err.Error())
+	}
+}
+
+// auditDenied records the request rejected by Auth, the actor is known only if the user is authenticated
+func auditDenied(r *http.Request, u *user.User, err error) {
+	e := newAudit(r, actionDenied, 0)
+	e.ActorId, e.Actor = 0, ""
+	if u != nil {
+		e.ActorId, e.Actor = u.Id, u.Login
+	}
+	saveAudit(e, err)
+}
+
+// auditTask runs the operation on the task of the entry and puts the changes of the task to the entry,
+// the operation returns the id of the task it creates
+func auditTask(repo storage.Repository, e *storage.AuditEntry, op func() (int, error)) (int, error) {
+	var before *task.Task
+	if e.TaskId > 0 {
+		before, _ = repo.GetTask(e.TaskId)
+	}
+	id, err := op()
+	if id > 0 {
+		e.TaskId = id
+	}
+	if err != nil {
+		return id, err
+	}
+	//the task done for the last time or moved to the trash isn't found after the operation
+	after, _ := repo.GetTask(e.TaskId)
+	e.Diff = auditDiff(before, after)
+	return id, nil
+}
+
+// auditDiff returns the JSON of the fields changed between the objects, nil object has no fields
+func auditDiff(before any, after any) storage.AuditDiff {
+	fields := func(v any) map[string]any {
+		m := map[string]any{}
+		data, _ := json.Marshal(v)
+		json.Unmarshal(data, &m)
+		return m
+	}
+	b, a := fields(before), fields(after)
+	diff := map[string]map[string]any{}
+	for name, value := range b {
+		if !reflect.DeepEqual(value, a[name]) {
+			diff[name] = map[string]any{"before": value, "after": a[name]}
+		}
+	}
+	for name, value := range a {
+		if _, ok := b[name]; !ok {
+			diff[name] = map[string]any{"before": nil, "after": value}
+		}
+	}
+	if len(diff) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(diff)
+	return storage.AuditDiff(data)
+}
+
+// GetAudit returns the audit log from the latest entry. It's filtered by the task, the login of the actor
+// and the days from and to inclusive in the 20060102 format, the next page starts before the entry id.
+func GetAudit(w http.ResponseWriter, r *http.Request) {
+	query := storage.AuditQuery{Actor: r.URL.Query().Get("actor"), Limit: cfg.Limit}
+	for param, value := range map[string]*int{"task_id": &query.TaskId, "before": &query.Before} {
+		if s := r.URL.Query().Get(param); len(s) > 0 {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 {
+				errorMessage(w, http.StatusBadRequest, param+" should be a positive number")
+				return
+			}
+			*value = n
+		}
+	}
+	if limit := r.URL.Query().Get("limit"); len(limit) > 0 {
+		limitInt, err := strconv.Atoi(limit)
+		if err != nil || limitInt < 1 {
+			errorMessage(w, http.StatusBadRequest, "limit should be a positive number")
+			return
+		}
+		query.Limit = min(limitInt, cfg.Limit)
+	}
+	var err error
+	loc := userNow(r).Location()
+	if query.From, err = historyBound(r.URL.Query().Get("from"), 0, loc); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if query.To, err = historyBound(r.URL.Query().Get("to"), 1, loc); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := store.GetAuditLog(query)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, &map[string]any{"entries": entries})
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/storage"
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+)
+
+const (
+	batchAllOrNothing = "all_or_nothing"
+	batchBestEffort   = "best_effort"
+
+	maxBatchOperations = 100
+)
+
+const (
+	opCreate = "create"
+	opUpdate = "update"
+	opDone   = "done"
+	opDelete = "delete"
+)
+
+type batchOperation struct {
+	Op    string     `json:"op"`
+	Id    string     `json:"id"`
+	Force bool       `json:"force"`
+	Task  *task.Task `json:"task"`
+	// IfMatch is the ETag of the task for the update and done operations, like the If-Match header
+	IfMatch string `json:"if_match"`
+}
+
+type batchRequest struct {
+	Mode       string           `json:"mode"`
+	Operations []batchOperation `json:"operations"`
+}
+
+// taskId returns the id of the task changed by the operation, zero if it isn't valid
+func (op batchOperation) taskId() int {
+	id := op.Id
+	if op.Task != nil && len(op.Task.Id) > 0 {
+		id = op.Task.Id
+	}
+	idInt, _ := strconv.Atoi(id)
+	return idInt
+}
+
+// runOperation runs the operation of the batch and returns the id of the created or the changed task
+func runOperation(repo storage.Repository, now time.Time, op batchOperation) (int, error) {
+	switch op.Op {
+	case opCreate, opUpdate:
+		if op.Task == nil {
+			return 0, failed(http.StatusBadRequest, "task is required")
+		}
+		if op.Op == opCreate {
+			return createTask(repo, now, op.Task)
+		}
+		//the id of the operation is used if the task doesn't have one
+		if len(op.Task.Id) == 0 {
+			op.Task.Id = op.Id
+		}
+		idInt, err := validateTaskID(op.Task.Id)
+		if err != nil {
+			return 0, failed(http.StatusBadRequest, err.Error())
+		}
+		return idInt, updateTask(repo, now, op.Task, op.IfMatch)
+	case opDone, opDelete:
+		idInt, err := validateTaskID(op.Id)
+		if err != nil {
+			return 0, failed(http.StatusBadRequest, err.Error())
+		}
+		if op.Op == opDone {
+			return idInt, doneTask(repo, now, idInt, op.Force, op.IfMatch)
+		}
+		return idInt, deleteTask(repo, idInt)
+	default:
+		return 0, failed(http.StatusBadRequest, fmt.Sprintf("op should be %s, %s, %s or %s", opCreate, opUpdate, opDone, opDelete))
+	}
+}
+
+// BatchTasks runs the operations on the tasks in a single transaction. In the all_or_nothing mode
+// the first failed operation rolls the batch back, in the best_effort mode the failed operations
+// are skipped and the rest are applied. Every operation runs in its own savepoint, so the changes
+// of the skipped one are rolled back. The failures of the storage roll the batch back in both modes.
+func BatchTasks(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	switch req.Mode {
+	case "":
+		req.Mode = batchAllOrNothing
+	case batchAllOrNothing, batchBestEffort:
+	default:
+		errorMessage(w, http.StatusBadRequest, fmt.Sprintf("mode should be %s or %s", batchAllOrNothing, batchBestEffort))
+		return
+	}
+	if len(req.Operations) == 0 {
+		errorMessage(w, http.StatusBadRequest, "no operations")
+		return
+	}
+	if len(req.Operations) > maxBatchOperations {
+		errorMessage(w, http.StatusBadRequest, fmt.Sprintf("batch could have at most %d operations", maxBatchOperations))
+		return
+	}
+
+	now := userNow(r)
+	results := make([]Result, len(req.Operations))
+	var failure *taskError
+	//the attempts are recorded when the outcome of the batch is known
+	audits, auditErrs := []*storage.AuditEntry{}, []error{}
+
+	err := userRepo(r).InTx(func(repo storage.Repository) error {
+		for i, op := range req.Operations {
+			action := op.Op
+			if action != opCreate && action != opUpdate && action != opDone && action != opDelete {
+				action = "unknown"
+			}
+			audit := newAudit(r, action, op.taskId())
+			var id int
+			err := repo.InTx(func(repo storage.Repository) error {
+				var err error
+				id, err = auditTask(repo, audit, func() (int, error) {
+					return runOperation(repo, now, op)
+				})
+				return err
+			})
+			audits, auditErrs = append(audits, audit), append(auditErrs, err)
+			results[i].Id = id
+			if errors.As(err, &failure) {
+				results[i].Error = failure.message
+				if req.Mode == batchAllOrNothing {
+					return err
+				}
+				failure = nil
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	for i, audit := range audits {
+		auditErr := auditErrs[i]
+		if auditErr == nil && (failure != nil || err != nil) {
+			audit.Diff = ""
+			auditErr = err
+			if failure != nil {
+				auditErr = failed(failure.status, "not applied, the batch is rolled back")
+			}
+		}
+		saveAudit(audit, auditErr)
+	}
+
+	if failure != nil {
+		//nothing is applied, the failed operation is the only one with its own error
+		for i := range results {
+			if len(results[i].Error) == 0 {
+				results[i] = Result{Error: "not applied, the batch is rolled back"}
+			}
+		}
+		writeJson(w, failure.status, &map[string]any{"results": results})
+		return
+	}
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, &map[string]any{"results": results})
+}
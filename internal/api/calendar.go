@@ -0,0 +1,305 @@
+package api
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/apikey"
+	"github.com/OlegShamkeev/go_final_project/internal/ical"
+	"github.com/OlegShamkeev/go_final_project/internal/nextdate"
+	"github.com/OlegShamkeev/go_final_project/internal/storage"
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+)
+
+const calendarProdId = "-//go_final_project//scheduler//RU"
+
+// maxImportSize limits size of the uploaded iCalendar file
+const maxImportSize = 10 << 20
+
+type importItem struct {
+	Index int    `json:"index"`
+	Uid   string `json:"uid,omitempty"`
+	Title string `json:"title,omitempty"`
+	Id    int    `json:"id,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type importReport struct {
+	Created  []importItem `json:"created"`
+	Rejected []importItem `json:"rejected"`
+}
+
+// GetCalendar renders all tasks of the user as iCalendar feed. Calendar clients couldn't send the auth cookie,
+// so the feed is opened by the API key of the calendar scope passed in the query, the tasks of its owner are rendered.
+func GetCalendar(w http.ResponseWriter, r *http.Request) {
+	ownerId := user.DefaultId
+	if len(cfg.Password) > 0 {
+		key, keyUser, status, err := authenticateApiKey(r.URL.Query().Get("token"))
+		if err != nil {
+			errorMessage(w, status, err.Error())
+			return
+		}
+		if key.Scope != apikey.ScopeCalendar {
+			errorMessage(w, http.StatusForbidden, "calendar feed is opened by the key of the calendar scope")
+			return
+		}
+		ownerId = keyUser.Id
+	}
+
+	componentName := "VEVENT"
+	if r.URL.Query().Get("type") == "todo" {
+		componentName = "VTODO"
+	}
+
+	tasks, err := store.ForUser(ownerId).GetAllTasks()
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	owner, err := store.GetUser(ownerId)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	calendar := ical.Component{Name: "VCALENDAR"}
+	calendar.Add("VERSION", "2.0")
+	calendar.Add("PRODID", calendarProdId)
+	calendar.Add("CALSCALE", "GREGORIAN")
+	calendar.Add("X-WR-CALNAME", "Scheduler")
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, t := range tasks {
+		calendar.Components = append(calendar.Components, taskToComponent(t, componentName, stamp, owner.TimeZone))
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=UTF-8")
+	w.Header().Set("Content-Disposition", `inline; filename="calendar.ics"`)
+	w.WriteHeader(http.StatusOK)
+	if err := ical.Encode(w, calendar); err != nil {
+		fmt.Fprintf(os.Stderr, "error during writing data to response writer %s", err.Error())
+	}
+}
+
+// taskToComponent renders the task, the time of the task is in its time zone or in the zone of the user
+func taskToComponent(t task.Task, componentName string, stamp string, userZone string) ical.Component {
+	c := ical.Component{Name: componentName}
+	c.Add("UID", fmt.Sprintf("task-%s@go_final_project", t.Id))
+	c.Add("DTSTAMP", stamp)
+	if len(t.Time) > 0 {
+		start := t.Date + "T" + strings.ReplaceAll(t.Time, ":", "") + "00"
+		//the time without the zone is the floating one, it is shown in the zone of the calendar
+		zoneParam := map[string]string{}
+		if zone := cmp.Or(t.TimeZone, userZone); len(zone) > 0 {
+			zoneParam["TZID"] = zone
+		}
+		c.AddWithParams("DTSTART", zoneParam, start)
+		if componentName == "VTODO" {
+			c.AddWithParams("DUE", zoneParam, start)
+		}
+	} else {
+		c.AddWithParams("DTSTART", map[string]string{"VALUE": "DATE"}, t.Date)
+	}
+	dateParam := map[string]string{"VALUE": "DATE"}
+	//the task takes the whole day, the end is exclusive
+	if date, err := time.Parse("20060102", t.Date); err == nil && len(t.Time) == 0 {
+		end := date.AddDate(0, 0, 1).Format("20060102")
+		if componentName == "VEVENT" {
+			c.AddWithParams("DTEND", dateParam, end)
+		} else {
+			c.AddWithParams("DUE", dateParam, end)
+		}
+	}
+	if componentName == "VTODO" {
+		c.Add("STATUS", "NEEDS-ACTION")
+	}
+	c.Add("SUMMARY", ical.Text(t.Title))
+	if len(t.Comment) > 0 {
+		c.Add("DESCRIPTION", ical.Text(t.Comment))
+	}
+	if len(t.Repeat) > 0 {
+		if rrule, err := nextdate.ToRRule(t.Repeat); err == nil {
+			c.Add("RRULE", rrule)
+		} else {
+			//keep the original rule for the rules which couldn't be expressed by RRULE
+			c.Add("X-SCHEDULER-REPEAT", ical.Text(t.Repeat))
+		}
+	}
+	return c
+}
+
+// ImportCalendar creates tasks from VEVENT and VTODO components of the uploaded iCalendar file.
+// The file is sent either as "file" field of multipart form or as the request body.
+// All accepted tasks are created in one transaction.
+func ImportCalendar(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	audit := newAudit(r, actionImport, 0)
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			saveAudit(audit, failed(http.StatusBadRequest, err.Error()))
+			errorMessage(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	calendars, err := ical.Decode(body)
+	if err != nil {
+		saveAudit(audit, failed(http.StatusBadRequest, err.Error()))
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report := importReport{Created: []importItem{}, Rejected: []importItem{}}
+	now := userNow(r)
+	var tasks []*task.Task
+	var accepted []importItem
+
+	index := 0
+	for _, calendar := range calendars {
+		for _, c := range calendar.Components {
+			if c.Name != "VEVENT" && c.Name != "VTODO" {
+				continue
+			}
+			item := importItem{Index: index}
+			index++
+			if uid, ok := c.Get("UID"); ok {
+				item.Uid = uid.Value
+			}
+
+			t, err := componentToTask(c, now.Location())
+			if err == nil {
+				item.Title = t.Title
+				if resultValidate := t.ValidateAndUpdateTask(now, false); resultValidate != "" {
+					err = errors.New(resultValidate)
+				}
+			}
+			if err != nil {
+				item.Error = err.Error()
+				report.Rejected = append(report.Rejected, item)
+				continue
+			}
+			tasks = append(tasks, t)
+			accepted = append(accepted, item)
+		}
+	}
+
+	err = userRepo(r).InTx(func(repo storage.Repository) error {
+		for i, t := range tasks {
+			id, err := repo.CreateTask(t)
+			if err != nil {
+				return err
+			}
+			accepted[i].Id = id
+		}
+		return nil
+	})
+	if err != nil {
+		saveAudit(audit, err)
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	report.Created = append(report.Created, accepted...)
+
+	//every created task gets its own entry, the import without them is recorded once
+	if len(accepted) == 0 {
+		saveAudit(audit, nil)
+	}
+	for _, item := range accepted {
+		e := *audit
+		e.TaskId = item.Id
+		after, _ := userRepo(r).GetTask(item.Id)
+		e.Diff = auditDiff(nil, after)
+		saveAudit(&e, nil)
+	}
+
+	writeJson(w, http.StatusOK, &report)
+}
+
+// componentToTask converts the component to the task, UTC time is converted to the zone of the user
+func componentToTask(c ical.Component, loc *time.Location) (*task.Task, error) {
+	if status, ok := c.Get("STATUS"); ok && strings.EqualFold(status.Value, "COMPLETED") {
+		return nil, fmt.Errorf("%s is already completed", strings.ToLower(c.Name))
+	}
+
+	t := &task.Task{}
+	if summary, ok := c.Get("SUMMARY"); ok {
+		t.Title = ical.UnescapeText(summary.Value)
+	}
+	if description, ok := c.Get("DESCRIPTION"); ok {
+		t.Comment = ical.UnescapeText(description.Value)
+	}
+
+	start, ok := c.Get("DTSTART")
+	if !ok && c.Name == "VTODO" {
+		start, ok = c.Get("DUE")
+	}
+	if ok {
+		if err := setIcalDate(t, start, loc); err != nil {
+			return nil, err
+		}
+	} else if c.Name == "VEVENT" {
+		return nil, fmt.Errorf("vevent has no dtstart")
+	}
+
+	if rrule, ok := c.Get("RRULE"); ok {
+		repeat, err := nextdate.FromRRule(rrule.Value)
+		if err != nil {
+			//rule is stored as is if the compact format can't express it
+			repeat = rrule.Value
+		}
+		t.Repeat = repeat
+	} else if repeat, ok := c.Get("X-SCHEDULER-REPEAT"); ok {
+		t.Repeat = ical.UnescapeText(repeat.Value)
+	}
+	return t, nil
+}
+
+// setIcalDate sets the date of the task from DATE or DATE-TIME value, DATE-TIME sets the time as well.
+// UTC time is converted to loc, the time with TZID keeps the zone if it is the IANA one.
+func setIcalDate(t *task.Task, p ical.Property, loc *time.Location) error {
+	value := p.Value
+	if strings.HasSuffix(value, "Z") {
+		d, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return err
+		}
+		d = d.In(loc)
+		t.Date, t.Time = d.Format("20060102"), d.Format(task.TimeFormat)
+		return nil
+	}
+	if len(value) < 8 {
+		return fmt.Errorf("incorrect date %q", value)
+	}
+	d, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return err
+	}
+	t.Date = d.Format("20060102")
+	if len(value) > 8 {
+		clock, err := time.Parse("T150405", value[8:])
+		if err != nil {
+			return err
+		}
+		t.Time = clock.Format(task.TimeFormat)
+		if zone := p.Params["TZID"]; len(zone) > 0 {
+			if _, err := task.LoadZone(zone); err == nil {
+				t.TimeZone = zone
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/OlegShamkeev/go_final_project/internal/storage"
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+)
+
+// GetTaskDependencies returns the dependencies of the task on its blockers and of the other tasks on it
+func GetTaskDependencies(w http.ResponseWriter, r *http.Request) {
+	idInt, err := validateTaskID(r.URL.Query().Get("id"))
+	if err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if denyTask(w, r, idInt, user.RoleViewer) {
+		return
+	}
+
+	dependencies, err := userRepo(r).GetDependencies(idInt)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, &map[string]any{"dependencies": dependencies})
+}
+
+// AddTaskDependency makes the task wait for the blocker, the editor role is required for the task
+// and the blocker should be visible to the user
+func AddTaskDependency(w http.ResponseWriter, r *http.Request) {
+	audit := newAudit(r, actionDependencyCreate, 0)
+	w = auditWriter(w, audit)
+	defer saveAudit(audit, nil)
+
+	var d task.Dependency
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	audit.TaskId = d.TaskId
+	if d.TaskId == d.BlockerId {
+		errorMessage(w, http.StatusBadRequest, "task can't depend on itself")
+		return
+	}
+	if denyTask(w, r, d.TaskId, user.RoleEditor) || denyTask(w, r, d.BlockerId, user.RoleViewer) {
+		return
+	}
+	for _, id := range []int{d.TaskId, d.BlockerId} {
+		if _, err := userRepo(r).GetTask(id); err != nil {
+			errorMessage(w, http.StatusNotFound, "task isn't found")
+			return
+		}
+	}
+
+	if err := userRepo(r).AddDependency(d); err != nil {
+		if errors.Is(err, storage.ErrDependencyCycle) {
+			errorMessage(w, http.StatusConflict, "blocker already depends on the task, the dependency would make a cycle")
+			return
+		}
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	audit.Diff = auditDiff(nil, d)
+
+	writeJson(w, http.StatusOK, &map[string]any{})
+}
+
+// DeleteTaskDependency lets the task go without waiting for the blocker
+func DeleteTaskDependency(w http.ResponseWriter, r *http.Request) {
+	taskId, err := validateTaskID(r.URL.Query().Get("task_id"))
+	audit := newAudit(r, actionDependencyDelete, taskId)
+	w = auditWriter(w, audit)
+	defer saveAudit(audit, nil)
+
+	if err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	blockerId, err := strconv.Atoi(r.URL.Query().Get("blocker_id"))
+	if err != nil {
+		errorMessage(w, http.StatusBadRequest, "blocker id should be a number")
+		return
+	}
+	if denyTask(w, r, taskId, user.RoleEditor) {
+		return
+	}
+
+	d := task.Dependency{TaskId: taskId, BlockerId: blockerId}
+	if err := userRepo(r).DeleteDependency(d); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorMessage(w, http.StatusNotFound, "dependency isn't found")
+			return
+		}
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	audit.Diff = auditDiff(d, nil)
+
+	writeJson(w, http.StatusOK, &map[string]any{})
+}
@@ -2,51 +2,45 @@ package api
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"encoding/hex"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math/rand"
+	"log"
 	"net/http"
 	"os"
 	"strconv"
 	"time"
 
+	"github.com/OlegShamkeev/go_final_project/internal/apikey"
 	"github.com/OlegShamkeev/go_final_project/internal/config"
 	"github.com/OlegShamkeev/go_final_project/internal/nextdate"
 	"github.com/OlegShamkeev/go_final_project/internal/storage"
 	"github.com/OlegShamkeev/go_final_project/internal/task"
-
-	"github.com/golang-jwt/jwt"
+	"github.com/OlegShamkeev/go_final_project/internal/user"
 )
 
 var cfg *config.Config
-var store *storage.Storage
-var secret []byte
-
-const secretLength = 20
+var store storage.Repository
 
 type Result struct {
 	Id    int    `json:"id,omitempty"`
 	Error string `json:"error,omitempty"`
 }
 
-func NewApi(config *config.Config, strg *storage.Storage) {
+func NewApi(config *config.Config, strg storage.Repository) error {
 	cfg = config
 	store = strg
 	if len(cfg.Password) > 0 {
-		secret = generateSecret()
+		if err := loadSigningKeys(); err != nil {
+			return err
+		}
+		return syncDefaultPassword()
 	}
-}
-
-func generateSecret() []byte {
-	rnd := rand.NewSource(time.Now().Unix())
-	result := make([]byte, 0, secretLength)
-	for i := 0; i < secretLength; i++ {
-		randomNumber := rnd.Int63()
-		result = append(result, byte(randomNumber%26+97))
+	if len(cfg.ViewerPassword) > 0 {
+		log.Printf("TODO_VIEWER_PASSWORD is ignored without TODO_PASSWORD, authentication is disabled\n")
 	}
-	return result
+	return nil
 }
 
 func validateTaskID(id string) (int, error) {
@@ -73,7 +67,8 @@ func GetNextDate(w http.ResponseWriter, r *http.Request) {
 	date := r.URL.Query().Get("date")
 	repeat := r.URL.Query().Get("repeat")
 
-	result, err := nextdate.NextDate(dNow, date, repeat, false)
+	//the date following now is returned even if the date itself hasn't come yet
+	result, err := nextdate.NextDate(dNow, date, repeat, true)
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -92,6 +87,9 @@ func GetNextDate(w http.ResponseWriter, r *http.Request) {
 }
 
 func errorMessage(w http.ResponseWriter, status uint, msg any) {
+	if a, ok := w.(*auditResponse); ok {
+		a.entry.Error = fmt.Sprint(msg)
+	}
 	writeJson(w, status,
 		&Result{Error: fmt.Sprint(msg)},
 	)
@@ -114,26 +112,25 @@ func PostTask(w http.ResponseWriter, r *http.Request) {
 
 	var task *task.Task
 	var buf bytes.Buffer
+	audit := newAudit(r, opCreate, 0)
 
 	_, err := buf.ReadFrom(r.Body)
-	if err != nil {
-		errorMessage(w, http.StatusBadRequest, err.Error())
-		return
+	if err == nil {
+		err = json.Unmarshal(buf.Bytes(), &task)
 	}
-	if err := json.Unmarshal(buf.Bytes(), &task); err != nil {
+	if err != nil {
+		saveAudit(audit, failed(http.StatusBadRequest, err.Error()))
 		errorMessage(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if resultValidate := task.ValidateAndUpdateTask(false); resultValidate != "" {
-		errorMessage(w, http.StatusBadRequest, resultValidate)
-		return
-	}
-
-	id, err := store.CreateTask(task)
+	id, err := auditTask(userRepo(r), audit, func() (int, error) {
+		return createTask(userRepo(r), userNow(r), task)
+	})
+	saveAudit(audit, err)
 
 	if err != nil {
-		errorMessage(w, http.StatusInternalServerError, err.Error())
+		operationFailed(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusCreated)
@@ -148,16 +145,79 @@ func PostTask(w http.ResponseWriter, r *http.Request) {
 func GetTasks(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
-	search := r.URL.Query().Get("search")
+	query := storage.TaskQuery{
+		Search: r.URL.Query().Get("search"),
+		Cursor: r.URL.Query().Get("cursor"),
+		Limit:  cfg.Limit,
+		From:   r.URL.Query().Get("from"),
+		To:     r.URL.Query().Get("to"),
+		Repeat: r.URL.Query().Get("repeat"),
+		Sort:   r.URL.Query().Get("sort"),
+		Now:    userNow(r),
+	}
+	if project := r.URL.Query().Get("project"); len(project) > 0 {
+		projectInt, err := strconv.Atoi(project)
+		if err != nil {
+			errorMessage(w, http.StatusBadRequest, "project should be a number")
+			return
+		}
+		query.Project = projectInt
+	}
+	if overdue := r.URL.Query().Get("overdue"); len(overdue) > 0 {
+		overdueBool, err := strconv.ParseBool(overdue)
+		if err != nil {
+			errorMessage(w, http.StatusBadRequest, "overdue should be true or false")
+			return
+		}
+		query.Overdue = overdueBool
+	}
+	query.Tags = r.URL.Query()["tag"]
+	switch r.URL.Query().Get("tag_mode") {
+	case "", "and":
+	case "or":
+		query.AnyTag = true
+	default:
+		errorMessage(w, http.StatusBadRequest, "tag_mode should be and or or")
+		return
+	}
+	switch r.URL.Query().Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Desc = true
+		//the reversed relevance of the search makes no sense, so the order applies to the date
+		if len(query.Sort) == 0 {
+			query.Sort = storage.SortDate
+		}
+	default:
+		errorMessage(w, http.StatusBadRequest, "order should be asc or desc")
+		return
+	}
+	if err := query.Validate(); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if limit := r.URL.Query().Get("limit"); len(limit) > 0 {
+		limitInt, err := strconv.Atoi(limit)
+		if err != nil || limitInt < 1 {
+			errorMessage(w, http.StatusBadRequest, "limit should be a positive number")
+			return
+		}
+		//page size is bounded by the configured limit
+		query.Limit = min(limitInt, cfg.Limit)
+	}
 
-	tasks, err := store.GetTasks(search)
+	page, err := userRepo(r).GetTasks(query)
+	if errors.Is(err, storage.ErrInvalidCursor) {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		errorMessage(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	res, _ := json.Marshal(&map[string][]task.Task{"tasks": tasks})
+	res, _ := json.Marshal(page)
 	_, err = w.Write(res)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error during writing data to response writer %s", err.Error())
@@ -176,12 +236,13 @@ func GetTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	task, err := store.GetTask(idInt)
+	task, err := userRepo(r).GetTask(idInt)
 
 	if err != nil {
 		errorMessage(w, http.StatusNotFound, err.Error())
 		return
 	}
+	w.Header().Set("ETag", etag(task))
 	w.WriteHeader(http.StatusOK)
 	res, _ := json.Marshal(task)
 	_, err = w.Write(res)
@@ -197,38 +258,25 @@ func UpdateTask(w http.ResponseWriter, r *http.Request) {
 
 	var buf bytes.Buffer
 	var task *task.Task
+	audit := newAudit(r, opUpdate, 0)
 
 	_, err := buf.ReadFrom(r.Body)
-
-	if err != nil {
-		errorMessage(w, http.StatusBadRequest, err.Error())
-		return
+	if err == nil {
+		err = json.Unmarshal(buf.Bytes(), &task)
 	}
-	if err := json.Unmarshal(buf.Bytes(), &task); err != nil {
-		errorMessage(w, http.StatusBadRequest, err.Error())
-		return
-	}
-
-	idInt, err := validateTaskID(task.Id)
 	if err != nil {
+		saveAudit(audit, failed(http.StatusBadRequest, err.Error()))
 		errorMessage(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	_, err = store.GetTask(idInt)
-
-	if err != nil {
-		errorMessage(w, http.StatusNotFound, err.Error())
-		return
-	}
-
-	if resultValidate := task.ValidateAndUpdateTask(false); resultValidate != "" {
-		errorMessage(w, http.StatusNotFound, resultValidate)
-		return
-	}
-	err = store.UpdateTask(task)
+	audit.TaskId, _ = strconv.Atoi(task.Id)
+	_, err = auditTask(userRepo(r), audit, func() (int, error) {
+		return 0, updateTask(userRepo(r), userNow(r), task, r.Header.Get("If-Match"))
+	})
+	saveAudit(audit, err)
 	if err != nil {
-		errorMessage(w, http.StatusNotFound, err.Error())
+		operationFailed(w, err)
 		return
 	}
 
@@ -247,32 +295,21 @@ func CheckDoneTask(w http.ResponseWriter, r *http.Request) {
 
 	id := r.URL.Query().Get("id")
 	idInt, err := validateTaskID(id)
+	audit := newAudit(r, opDone, idInt)
 
 	if err != nil {
+		saveAudit(audit, failed(http.StatusBadRequest, err.Error()))
 		errorMessage(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	task, err := store.GetTask(idInt)
-
-	if err != nil {
-		errorMessage(w, http.StatusNotFound, err.Error())
-		return
-	}
-
-	if len(task.Repeat) == 0 {
-		DeleteTask(w, r)
-		return
-	}
-
-	if resultValidate := task.ValidateAndUpdateTask(true); resultValidate != "" {
-		errorMessage(w, http.StatusBadRequest, resultValidate)
-		return
-	}
 
-	err = store.UpdateTask(task)
+	_, err = auditTask(userRepo(r), audit, func() (int, error) {
+		return 0, doneTask(userRepo(r), userNow(r), idInt, r.URL.Query().Get("force") == "true", r.Header.Get("If-Match"))
+	})
+	saveAudit(audit, err)
 
 	if err != nil {
-		errorMessage(w, http.StatusBadRequest, err.Error())
+		operationFailed(w, err)
 		return
 	}
 
@@ -290,16 +327,21 @@ func DeleteTask(w http.ResponseWriter, r *http.Request) {
 
 	id := r.URL.Query().Get("id")
 	idInt, err := validateTaskID(id)
+	audit := newAudit(r, opDelete, idInt)
 
 	if err != nil {
+		saveAudit(audit, failed(http.StatusBadRequest, err.Error()))
 		errorMessage(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	err = store.DeleteTask(idInt)
+	_, err = auditTask(userRepo(r), audit, func() (int, error) {
+		return 0, deleteTask(userRepo(r), idInt)
+	})
+	saveAudit(audit, err)
 
 	if err != nil {
-		errorMessage(w, http.StatusNotFound, err.Error())
+		operationFailed(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -315,85 +357,108 @@ func AuthAndGenerateToken(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
 	var buf bytes.Buffer
+	//the actor is the user signing in, not the one of the request
+	audit := newAudit(r, actionSignIn, 0)
+	audit.ActorId, audit.Actor = 0, ""
 
 	_, err := buf.ReadFrom(r.Body)
+	var c credentials
+	if err == nil {
+		err = json.Unmarshal(buf.Bytes(), &c)
+	}
 	if err != nil {
+		saveAudit(audit, failed(http.StatusBadRequest, err.Error()))
 		errorMessage(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	p := map[string]string{}
-	if err := json.Unmarshal(buf.Bytes(), &p); err != nil {
-		errorMessage(w, http.StatusBadRequest, err.Error())
+	//the login form of the web interface sends the password of the default user only
+	if len(c.Login) == 0 {
+		c.Login = user.DefaultLogin
+	}
+	audit.Actor = c.Login
+	//the tokens aren't signed without TODO_PASSWORD, TODO_VIEWER_PASSWORD alone doesn't turn authentication on
+	if len(cfg.Password) == 0 {
+		saveAudit(audit, failed(http.StatusForbidden, "authentication is disabled"))
+		errorMessage(w, http.StatusForbidden, "authentication is disabled")
 		return
 	}
 
-	if p["password"] != cfg.Password {
-		errorMessage(w, http.StatusUnauthorized, "wrong password")
+	u, err := store.GetUserByLogin(c.Login)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		saveAudit(audit, err)
+		errorMessage(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-
-	result := sha256.Sum256([]byte(cfg.Password))
-	claims := jwt.MapClaims{
-		"hashPass": hex.EncodeToString(result[:]),
+	if err != nil {
+		//the unknown login isn't told apart from the wrong password by the time of the response
+		user.CheckDummyPassword(c.Password)
+		saveAudit(audit, failed(http.StatusUnauthorized, "wrong login or password"))
+		errorMessage(w, http.StatusUnauthorized, "wrong login or password")
+		return
+	}
+	audit.ActorId = u.Id
+	role := u.Role
+	if !u.CheckPassword(c.Password) {
+		if !isViewerPassword(u, c.Password) {
+			saveAudit(audit, failed(http.StatusUnauthorized, "wrong login or password"))
+			errorMessage(w, http.StatusUnauthorized, "wrong login or password")
+			return
+		}
+		role = user.RoleViewer
 	}
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	signedToken, err := jwtToken.SignedString(secret)
+	tokens, err := issueTokens(u, role)
+	saveAudit(audit, err)
 	if err != nil {
 		errorMessage(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	res, _ := json.Marshal(&map[string]string{"token": signedToken})
-	_, err = w.Write(res)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error during writing data to response writer %s", err.Error())
-		return
-	}
+	writeJson(w, http.StatusOK, tokens)
 }
 
-func Auth(next http.HandlerFunc) http.HandlerFunc {
+// Auth authenticates the request by the token or the API key and checks that the granted role
+// is the required one or higher
+func Auth(required string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if len(cfg.Password) > 0 {
 			w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 
-			var token string
-			cookie, err := r.Cookie("token")
-			if err == nil {
-				token = cookie.Value
+			var u *user.User
+			var role string
+			deny := func(status uint, msg string) {
+				if status == http.StatusUnauthorized || status == http.StatusForbidden {
+					auditDenied(r, u, failed(status, msg))
+				}
+				errorMessage(w, status, msg)
 			}
-
-			jwtToken, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-				return secret, nil
-			})
-
-			if err != nil {
-				errorMessage(w, http.StatusUnauthorized, err.Error())
-				return
-			}
-			if !jwtToken.Valid {
-				errorMessage(w, http.StatusUnauthorized, "jwt token isn't valid")
-				return
-			}
-
-			res, ok := jwtToken.Claims.(jwt.MapClaims)
-			if !ok {
-				errorMessage(w, http.StatusUnauthorized, "failed to typecast to jwt.MapCalims")
-				return
-			}
-
-			hashPassRaw := res["hashPass"]
-			hashPass, ok := hashPassRaw.(string)
-			if !ok {
-				errorMessage(w, http.StatusUnauthorized, "failed to typecase password hash to string")
-				return
+			token := requestToken(r)
+			if apikey.IsKey(token) {
+				key, keyUser, status, err := authenticateApiKey(token)
+				if err != nil {
+					deny(status, err.Error())
+					return
+				}
+				u = keyUser
+				if key.Scope == apikey.ScopeCalendar {
+					deny(http.StatusForbidden, "calendar key opens only the calendar feed")
+					return
+				}
+				role = key.GrantedRole(keyUser.Role)
+				r = withApiKey(r, key)
+			} else {
+				claims, tokenUser, err := parseToken(token, tokenAccess)
+				if err != nil {
+					deny(http.StatusUnauthorized, err.Error())
+					return
+				}
+				u, role = tokenUser, claims.grantedRole(tokenUser)
 			}
 
-			result := sha256.Sum256([]byte(cfg.Password))
-			if hashPass != hex.EncodeToString(result[:]) {
-				errorMessage(w, http.StatusUnauthorized, "token password hash doesn't match")
+			if !user.Permits(role, required) {
+				deny(http.StatusForbidden, fmt.Sprintf("%s role is required, granted role is %s", required, role))
 				return
 			}
+			r = withUser(r, u, role)
 		}
 		next(w, r)
 	})
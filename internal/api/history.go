@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"time"
+)
+
+// GetTaskHistory returns completions of the task starting from the latest one
+func GetTaskHistory(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	idInt, err := validateTaskID(id)
+
+	if err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	completions, err := userRepo(r).GetTaskCompletions(idInt)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, &map[string]any{"completions": completions})
+}
+
+// GetHistory returns completions made between from and to days inclusive, days are in the 20060102 format
+func GetHistory(w http.ResponseWriter, r *http.Request) {
+	loc := userNow(r).Location()
+	from, err := historyBound(r.URL.Query().Get("from"), 0, loc)
+	if err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, err := historyBound(r.URL.Query().Get("to"), 1, loc)
+	if err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	completions, err := userRepo(r).GetCompletions(from, to)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, &map[string]any{"completions": completions})
+}
+
+// historyBound converts the day in the zone of the user to the UTC timestamp of its beginning shifted by days
+func historyBound(day string, days int, loc *time.Location) (string, error) {
+	if len(day) == 0 {
+		return "", nil
+	}
+	d, err := time.ParseInLocation("20060102", day, loc)
+	if err != nil {
+		return "", err
+	}
+	return d.AddDate(0, 0, days).UTC().Format(time.RFC3339), nil
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// itemsTask returns the id of the task from the path, the response is written if the task
+// isn't available to the current user with the role
+func itemsTask(w http.ResponseWriter, r *http.Request, required string) (int, bool) {
+	idInt, err := validateTaskID(chi.URLParam(r, "id"))
+	if err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return 0, false
+	}
+	if denyTask(w, r, idInt, required) {
+		return 0, false
+	}
+	//the checklist of the task in the trash isn't available until the task is restored
+	if _, err := userRepo(r).GetTask(idInt); err != nil {
+		errorMessage(w, http.StatusNotFound, "task isn't found")
+		return 0, false
+	}
+	return idInt, true
+}
+
+// GetTaskItems returns the checklist of the task
+func GetTaskItems(w http.ResponseWriter, r *http.Request) {
+	taskId, ok := itemsTask(w, r, user.RoleViewer)
+	if !ok {
+		return
+	}
+
+	items, err := userRepo(r).GetItems(taskId)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, &map[string]any{"items": items})
+}
+
+// itemById returns the item of the checklist of the task, nil if it isn't found
+func itemById(r *http.Request, taskId int, itemId int) *task.Item {
+	items, _ := userRepo(r).GetItems(taskId)
+	for _, item := range items {
+		if item.Id == itemId {
+			return &item
+		}
+	}
+	return nil
+}
+
+// AddTaskItem adds the item to the end of the checklist of the task
+func AddTaskItem(w http.ResponseWriter, r *http.Request) {
+	//the task of the path is recorded even if it isn't available
+	pathId, _ := validateTaskID(chi.URLParam(r, "id"))
+	audit := newAudit(r, actionItemCreate, pathId)
+	w = auditWriter(w, audit)
+	defer saveAudit(audit, nil)
+
+	var item task.Item
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if resultValidate := item.Validate(); resultValidate != "" {
+		errorMessage(w, http.StatusBadRequest, resultValidate)
+		return
+	}
+	taskId, ok := itemsTask(w, r, user.RoleEditor)
+	if !ok {
+		return
+	}
+	item.TaskId = taskId
+
+	items, err := userRepo(r).GetItems(taskId)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(items) >= task.MaxItems {
+		errorMessage(w, http.StatusBadRequest, "task could have at most 100 items")
+		return
+	}
+
+	id, err := userRepo(r).AddItem(&item)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	item.Id = id
+	audit.Diff = auditDiff(nil, item)
+
+	writeJson(w, http.StatusCreated, &Result{Id: id})
+}
+
+// UpdateTaskItem changes the title and the done state of the item
+func UpdateTaskItem(w http.ResponseWriter, r *http.Request) {
+	pathId, _ := validateTaskID(chi.URLParam(r, "id"))
+	audit := newAudit(r, actionItemUpdate, pathId)
+	w = auditWriter(w, audit)
+	defer saveAudit(audit, nil)
+
+	var item task.Item
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if resultValidate := item.Validate(); resultValidate != "" {
+		errorMessage(w, http.StatusBadRequest, resultValidate)
+		return
+	}
+	taskId, ok := itemsTask(w, r, user.RoleEditor)
+	if !ok {
+		return
+	}
+	item.TaskId = taskId
+	before := itemById(r, taskId, item.Id)
+
+	if err := userRepo(r).UpdateItem(&item); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorMessage(w, http.StatusNotFound, "item isn't found")
+			return
+		}
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	audit.Diff = auditDiff(before, itemById(r, taskId, item.Id))
+
+	writeJson(w, http.StatusOK, &map[string]any{})
+}
+
+// DeleteTaskItem deletes the item from the checklist of the task
+func DeleteTaskItem(w http.ResponseWriter, r *http.Request) {
+	pathId, _ := validateTaskID(chi.URLParam(r, "id"))
+	audit := newAudit(r, actionItemDelete, pathId)
+	w = auditWriter(w, audit)
+	defer saveAudit(audit, nil)
+
+	itemId, err := strconv.Atoi(r.URL.Query().Get("item_id"))
+	if err != nil {
+		errorMessage(w, http.StatusBadRequest, "item id should be a number")
+		return
+	}
+	taskId, ok := itemsTask(w, r, user.RoleEditor)
+	if !ok {
+		return
+	}
+	before := itemById(r, taskId, itemId)
+
+	if err := userRepo(r).DeleteItem(taskId, itemId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorMessage(w, http.StatusNotFound, "item isn't found")
+			return
+		}
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	audit.Diff = auditDiff(before, nil)
+
+	writeJson(w, http.StatusOK, &map[string]any{})
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/storage"
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+)
+
+// taskError is the expected failure of the operation on the task with the status of the response,
+// the other errors of the operations are failures of the storage
+type taskError struct {
+	status  uint
+	message string
+	// current is the stored task returned instead of the message if the update is in conflict with it
+	current *task.Task
+}
+
+func (e *taskError) Error() string {
+	return e.message
+}
+
+func failed(status uint, message string) error {
+	return &taskError{status: status, message: message}
+}
+
+// operationFailed writes the response for the error of the operation on the task
+func operationFailed(w http.ResponseWriter, err error) {
+	var opErr *taskError
+	if errors.As(err, &opErr) && opErr.current != nil {
+		w.Header().Set("ETag", etag(opErr.current))
+		writeJson(w, opErr.status, opErr.current)
+		return
+	}
+	if errors.As(err, &opErr) {
+		errorMessage(w, opErr.status, opErr.message)
+		return
+	}
+	errorMessage(w, http.StatusInternalServerError, err.Error())
+}
+
+// etag returns the entity tag of the version of the task
+func etag(t *task.Task) string {
+	return fmt.Sprintf(`"%d"`, t.Version)
+}
+
+// conflict returns the stored copy of the task changed by someone else
+func conflict(repo storage.Repository, idInt int) error {
+	current, err := repo.GetTask(idInt)
+	if err != nil {
+		return failed(http.StatusNotFound, err.Error())
+	}
+	return &taskError{status: http.StatusPreconditionFailed, message: storage.ErrVersionConflict.Error(), current: current}
+}
+
+// matchVersion checks the If-Match value against the version of the stored task and returns the version
+// the update should be applied to, zero version overwrites the task
+func matchVersion(ifMatch string, current *task.Task) (int, error) {
+	if len(ifMatch) == 0 {
+		if cfg.RequireIfMatch {
+			return 0, failed(http.StatusPreconditionRequired, "If-Match header with the ETag of the task is required")
+		}
+		return 0, nil
+	}
+	if strings.TrimSpace(ifMatch) == "*" {
+		return 0, nil
+	}
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag(current) {
+			return current.Version, nil
+		}
+	}
+	return 0, &taskError{status: http.StatusPreconditionFailed, message: storage.ErrVersionConflict.Error(), current: current}
+}
+
+// checkProjectRole checks the role of the user of the repository in the project
+func checkProjectRole(repo storage.Repository, projectId string, required string) error {
+	id, err := strconv.Atoi(projectId)
+	if err != nil {
+		return failed(http.StatusBadRequest, "project id should be a number")
+	}
+	p, err := repo.GetProject(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return failed(http.StatusNotFound, "project isn't found")
+	}
+	if err != nil {
+		return err
+	}
+	if !user.Permits(p.Role, required) {
+		return failed(http.StatusForbidden, fmt.Sprintf("%s role in the project is required, the role is %s", required, p.Role))
+	}
+	return nil
+}
+
+// checkTaskRole checks the role of the user of the repository in the project of the task
+func checkTaskRole(repo storage.Repository, taskId int, required string) error {
+	role, err := repo.GetTaskRole(taskId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return failed(http.StatusNotFound, "task isn't found")
+	}
+	if err != nil {
+		return err
+	}
+	if !user.Permits(role, required) {
+		return failed(http.StatusForbidden, fmt.Sprintf("%s role in the project is required, the role is %s", required, role))
+	}
+	return nil
+}
+
+// createTask validates the task and creates it, the task without the project goes to the default project of the user
+func createTask(repo storage.Repository, now time.Time, t *task.Task) (int, error) {
+	if resultValidate := t.ValidateAndUpdateTask(now, false); resultValidate != "" {
+		return 0, failed(http.StatusBadRequest, resultValidate)
+	}
+	if len(t.ProjectId) > 0 {
+		if err := checkProjectRole(repo, t.ProjectId, user.RoleEditor); err != nil {
+			return 0, err
+		}
+	}
+	return repo.CreateTask(t)
+}
+
+// updateTask validates the task and replaces the stored one if it matches ifMatch
+func updateTask(repo storage.Repository, now time.Time, t *task.Task, ifMatch string) error {
+	idInt, err := validateTaskID(t.Id)
+	if err != nil {
+		return failed(http.StatusBadRequest, err.Error())
+	}
+	current, err := repo.GetTask(idInt)
+	if err != nil {
+		return failed(http.StatusNotFound, err.Error())
+	}
+	if err := checkTaskRole(repo, idInt, user.RoleEditor); err != nil {
+		return err
+	}
+	//moving to the other project requires the editor role there as well
+	if len(t.ProjectId) > 0 && t.ProjectId != current.ProjectId {
+		if err := checkProjectRole(repo, t.ProjectId, user.RoleEditor); err != nil {
+			return err
+		}
+	}
+	if t.Version, err = matchVersion(ifMatch, current); err != nil {
+		return err
+	}
+	if resultValidate := t.ValidateAndUpdateTask(now, false); resultValidate != "" {
+		return failed(http.StatusNotFound, resultValidate)
+	}
+	err = repo.UpdateTask(t)
+	if errors.Is(err, storage.ErrVersionConflict) {
+		return conflict(repo, idInt)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return failed(http.StatusNotFound, err.Error())
+	}
+	return err
+}
+
+// doneTask records the completion of the task and moves the repeating task to the next date,
+// the other task is deleted. The task with open blockers is done only if force is set.
+// The repeating task is moved only if it hasn't been changed since it was read.
+func doneTask(repo storage.Repository, now time.Time, idInt int, force bool, ifMatch string) error {
+	t, err := repo.GetTask(idInt)
+	if err != nil {
+		return failed(http.StatusNotFound, err.Error())
+	}
+	if err := checkTaskRole(repo, idInt, user.RoleEditor); err != nil {
+		return err
+	}
+	if _, err := matchVersion(ifMatch, t); err != nil {
+		return err
+	}
+	if t.Blocked && !force {
+		blockers, err := repo.GetOpenBlockers(idInt)
+		if err != nil {
+			return err
+		}
+		return failed(http.StatusConflict, fmt.Sprintf("task is blocked by the open tasks %s, pass force=true to complete it anyway",
+			strings.Trim(fmt.Sprint(blockers), "[]")))
+	}
+
+	completion := t.Completion(time.Now())
+
+	if len(t.Repeat) > 0 {
+		if resultValidate := t.ValidateAndUpdateTask(now, true); resultValidate != "" {
+			return failed(http.StatusBadRequest, resultValidate)
+		}
+	}
+
+	err = repo.InTx(func(repo storage.Repository) error {
+		if _, err := repo.AddCompletion(completion); err != nil {
+			return err
+		}
+		//task without repeat rule or with the finished one is done completely
+		if len(t.Repeat) == 0 {
+			return repo.DeleteTask(idInt)
+		}
+		if err := repo.UpdateTask(t); err != nil {
+			return err
+		}
+		//the checklist starts over for the next date of the task
+		return repo.ResetItems(idInt)
+	})
+	if errors.Is(err, storage.ErrVersionConflict) {
+		return conflict(repo, idInt)
+	}
+	return err
+}
+
+// deleteTask moves the task to the trash
+func deleteTask(repo storage.Repository, idInt int) error {
+	if err := checkTaskRole(repo, idInt, user.RoleEditor); err != nil {
+		return err
+	}
+	err := repo.TrashTask(idInt, time.Now().UTC().Format(time.RFC3339))
+	if errors.Is(err, sql.ErrNoRows) {
+		return failed(http.StatusNotFound, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,258 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/OlegShamkeev/go_final_project/internal/project"
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+)
+
+type memberRequest struct {
+	ProjectId int    `json:"project_id"`
+	Login     string `json:"login"`
+	Role      string `json:"role"`
+}
+
+// denyProject checks the role of the current user in the project, the response is written if it's denied
+func denyProject(w http.ResponseWriter, r *http.Request, projectId string, required string) bool {
+	if err := checkProjectRole(userRepo(r), projectId, required); err != nil {
+		operationFailed(w, err)
+		return true
+	}
+	return false
+}
+
+// denyTask checks the role of the current user in the project of the task, the response is written if it's denied
+func denyTask(w http.ResponseWriter, r *http.Request, taskId int, required string) bool {
+	if err := checkTaskRole(userRepo(r), taskId, required); err != nil {
+		operationFailed(w, err)
+		return true
+	}
+	return false
+}
+
+// GetProjects returns the projects of the user with the roles in them, or the project by id
+func GetProjects(w http.ResponseWriter, r *http.Request) {
+	if id := r.URL.Query().Get("id"); len(id) > 0 {
+		idInt, err := strconv.Atoi(id)
+		if err != nil {
+			errorMessage(w, http.StatusBadRequest, "project id should be a number")
+			return
+		}
+		p, err := userRepo(r).GetProject(idInt)
+		if err != nil {
+			errorMessage(w, http.StatusNotFound, "project isn't found")
+			return
+		}
+		writeJson(w, http.StatusOK, p)
+		return
+	}
+
+	projects, err := userRepo(r).GetProjects()
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, &map[string]any{"projects": projects})
+}
+
+// CreateProject makes the current user the admin of the new project
+func CreateProject(w http.ResponseWriter, r *http.Request) {
+	audit := newAudit(r, actionProjectCreate, 0)
+	w = auditWriter(w, audit)
+	defer saveAudit(audit, nil)
+
+	var p project.Project
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if resultValidate := p.Validate(); resultValidate != "" {
+		errorMessage(w, http.StatusBadRequest, resultValidate)
+		return
+	}
+	p.IsDefault = false
+
+	id, err := userRepo(r).CreateProject(&p)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	p.Id = id
+	audit.Diff = auditDiff(nil, p)
+
+	writeJson(w, http.StatusCreated, &Result{Id: id})
+}
+
+// UpdateProject renames the project
+func UpdateProject(w http.ResponseWriter, r *http.Request) {
+	audit := newAudit(r, actionProjectUpdate, 0)
+	w = auditWriter(w, audit)
+	defer saveAudit(audit, nil)
+
+	var p project.Project
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if resultValidate := p.Validate(); resultValidate != "" {
+		errorMessage(w, http.StatusBadRequest, resultValidate)
+		return
+	}
+	if denyProject(w, r, strconv.Itoa(p.Id), user.RoleAdmin) {
+		return
+	}
+	before, _ := userRepo(r).GetProject(p.Id)
+
+	if err := userRepo(r).UpdateProject(&p); err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	after, _ := userRepo(r).GetProject(p.Id)
+	audit.Diff = auditDiff(before, after)
+
+	writeJson(w, http.StatusOK, &map[string]any{})
+}
+
+// DeleteProject deletes the project with its tasks, the default project of the user can't be deleted
+func DeleteProject(w http.ResponseWriter, r *http.Request) {
+	audit := newAudit(r, actionProjectDelete, 0)
+	w = auditWriter(w, audit)
+	defer saveAudit(audit, nil)
+
+	id := r.URL.Query().Get("id")
+	if denyProject(w, r, id, user.RoleAdmin) {
+		return
+	}
+	idInt, _ := strconv.Atoi(id)
+	p, err := userRepo(r).GetProject(idInt)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if p.IsDefault {
+		errorMessage(w, http.StatusBadRequest, "default project can't be deleted")
+		return
+	}
+
+	if err := userRepo(r).DeleteProject(idInt); err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	audit.Diff = auditDiff(p, nil)
+
+	writeJson(w, http.StatusOK, &map[string]any{})
+}
+
+// GetProjectMembers returns the members of the project with their roles
+func GetProjectMembers(w http.ResponseWriter, r *http.Request) {
+	projectId := r.URL.Query().Get("project_id")
+	if denyProject(w, r, projectId, user.RoleViewer) {
+		return
+	}
+	idInt, _ := strconv.Atoi(projectId)
+
+	members, err := userRepo(r).GetMembers(idInt)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, &map[string]any{"members": members})
+}
+
+// SetProjectMember adds the user to the project or changes the role, the owner stays the admin
+func SetProjectMember(w http.ResponseWriter, r *http.Request) {
+	audit := newAudit(r, actionMemberSet, 0)
+	w = auditWriter(w, audit)
+	defer saveAudit(audit, nil)
+
+	var m memberRequest
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !user.ValidRole(m.Role) {
+		errorMessage(w, http.StatusBadRequest, "role should be viewer, editor or admin")
+		return
+	}
+	if denyProject(w, r, strconv.Itoa(m.ProjectId), user.RoleAdmin) {
+		return
+	}
+
+	u, err := store.GetUserByLogin(m.Login)
+	if errors.Is(err, sql.ErrNoRows) {
+		errorMessage(w, http.StatusNotFound, "user isn't found")
+		return
+	}
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	p, err := userRepo(r).GetProject(m.ProjectId)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if u.Id == p.OwnerId && m.Role != user.RoleAdmin {
+		errorMessage(w, http.StatusBadRequest, "role of the owner of the project can't be changed")
+		return
+	}
+
+	if err := store.SetMember(m.ProjectId, u.Id, m.Role); err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	audit.Diff = auditDiff(nil, m)
+
+	writeJson(w, http.StatusOK, &map[string]any{})
+}
+
+// DeleteProjectMember removes the user from the project, the admin removes anyone except of the owner
+// and the members leave the project themselves
+func DeleteProjectMember(w http.ResponseWriter, r *http.Request) {
+	audit := newAudit(r, actionMemberDelete, 0)
+	w = auditWriter(w, audit)
+	defer saveAudit(audit, nil)
+
+	projectId := r.URL.Query().Get("project_id")
+	userId, err := strconv.Atoi(r.URL.Query().Get("user_id"))
+	if err != nil {
+		errorMessage(w, http.StatusBadRequest, "user id should be a number")
+		return
+	}
+	required := user.RoleAdmin
+	if userId == currentUser(r).Id {
+		required = user.RoleViewer
+	}
+	if denyProject(w, r, projectId, required) {
+		return
+	}
+	idInt, _ := strconv.Atoi(projectId)
+	p, err := userRepo(r).GetProject(idInt)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if userId == p.OwnerId {
+		errorMessage(w, http.StatusBadRequest, "owner of the project can't be removed")
+		return
+	}
+
+	if err := store.DeleteMember(idInt, userId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorMessage(w, http.StatusNotFound, "member isn't found")
+			return
+		}
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	audit.Diff = auditDiff(map[string]any{"project_id": idInt, "user_id": userId}, nil)
+
+	writeJson(w, http.StatusOK, &map[string]any{})
+}
@@ -0,0 +1,16 @@
+package api
+
+import (
+	"net/http"
+)
+
+// GetTags returns the tags of the tasks of the user with the number of the tasks marked by them
+func GetTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := userRepo(r).GetTags()
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, &map[string]any{"tags": tags})
+}
@@ -0,0 +1,344 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/config"
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	tokenAccess  = "access"
+	tokenRefresh = "refresh"
+)
+
+// keyLength is the size of the generated signing key in bytes
+const keyLength = 32
+
+type tokenClaims struct {
+	jwt.StandardClaims
+	UserId int `json:"uid"`
+	// HashPass invalidates the token when the password is changed
+	HashPass string `json:"hashPass"`
+	Type     string `json:"typ"`
+	// Role is granted by the credential, the current role of the user limits it
+	Role string `json:"role"`
+}
+
+type tokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// signingKey is the HMAC key of the tokens, its id is put to the kid header of the token
+type signingKey struct {
+	id  string
+	key []byte
+	// until limits the usage of the previous key by the grace window
+	until time.Time
+}
+
+var currentKey, previousKey *signingKey
+
+func newSigningKey(key []byte, until time.Time) *signingKey {
+	sum := sha256.Sum256(key)
+	return &signingKey{id: hex.EncodeToString(sum[:8]), key: key, until: until}
+}
+
+// loadSigningKeys takes the keys from the config or from the key file, the missing file is generated.
+// The previous key is kept in the file with .previous suffix by RotateSigningKey.
+func loadSigningKeys() error {
+	if len(cfg.JWTKey) > 0 {
+		currentKey = newSigningKey([]byte(cfg.JWTKey), time.Time{})
+		if len(cfg.JWTPreviousKey) > 0 {
+			//the moment of rotation is unknown, so the grace window starts with the server
+			previousKey = newSigningKey([]byte(cfg.JWTPreviousKey), time.Now().Add(cfg.JWTKeyGrace))
+		}
+		return nil
+	}
+
+	path := keyFilePath()
+	key, _, err := readKeyFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("Generating JWT signing key in %s\n", path)
+		key, err = writeNewKey(path)
+	}
+	if err != nil {
+		return err
+	}
+	currentKey = newSigningKey(key, time.Time{})
+
+	previous, rotatedAt, err := readKeyFile(path + ".previous")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	previousKey = newSigningKey(previous, rotatedAt.Add(cfg.JWTKeyGrace))
+	return nil
+}
+
+// RotateSigningKey generates the new key in the key file, the current one is moved to the .previous file.
+// Tokens signed by the previous key are accepted during TODO_JWT_KEY_GRACE after rotation.
+func RotateSigningKey(config *config.Config) error {
+	cfg = config
+	if len(cfg.JWTKey) > 0 {
+		return fmt.Errorf("key is set by TODO_JWT_KEY, it should be rotated there with TODO_JWT_PREVIOUS_KEY")
+	}
+	path := keyFilePath()
+	if err := os.Rename(path, path+".previous"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	//the modification time of the previous key is the start of the grace window
+	now := time.Now()
+	if err := os.Chtimes(path+".previous", now, now); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	_, err := writeNewKey(path)
+	if err == nil {
+		log.Printf("JWT signing key in %s is rotated\n", path)
+	}
+	return err
+}
+
+// keyFilePath returns TODO_JWT_KEY_FILE or jwt.key in the directory of the database file
+func keyFilePath() string {
+	if len(cfg.JWTKeyFile) > 0 {
+		return cfg.JWTKeyFile
+	}
+	if len(cfg.DBPath) > 0 {
+		return filepath.Join(filepath.Dir(cfg.DBPath), "jwt.key")
+	}
+	return "jwt.key"
+}
+
+// readKeyFile returns the hex decoded key and the modification time of the file
+func readKeyFile(path string) ([]byte, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) == 0 {
+		return nil, time.Time{}, fmt.Errorf("key file %s should contain hex encoded key", path)
+	}
+	return key, info.ModTime(), nil
+}
+
+func writeNewKey(path string) ([]byte, error) {
+	key := make([]byte, keyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func newJti() (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(jti), nil
+}
+
+func issueToken(u *user.User, role string, tokenType string, ttl time.Duration) (string, error) {
+	jti, err := newJti()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := tokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
+		UserId:   u.Id,
+		HashPass: passwordFingerprint(u),
+		Type:     tokenType,
+		Role:     role,
+	}
+	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	jwtToken.Header["kid"] = currentKey.id
+	return jwtToken.SignedString(currentKey.key)
+}
+
+func issueTokens(u *user.User, role string) (*tokenPair, error) {
+	token, err := issueToken(u, role, tokenAccess, cfg.TokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := issueToken(u, role, tokenRefresh, cfg.RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenPair{Token: token, RefreshToken: refreshToken}, nil
+}
+
+func keyFunc(t *jwt.Token) (interface{}, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+	}
+	kid, _ := t.Header["kid"].(string)
+	if kid == currentKey.id {
+		return currentKey.key, nil
+	}
+	if previousKey != nil && kid == previousKey.id && time.Now().Before(previousKey.until) {
+		return previousKey.key, nil
+	}
+	return nil, fmt.Errorf("token is signed by unknown key")
+}
+
+// grantedRole is the role of the token limited by the current role of the user,
+// so the demotion of the user takes effect before the token expires
+func (c *tokenClaims) grantedRole(u *user.User) string {
+	return user.LowerRole(c.Role, u.Role)
+}
+
+// parseToken checks the token of the type and returns its claims and user
+func parseToken(token string, tokenType string) (*tokenClaims, *user.User, error) {
+	claims := &tokenClaims{}
+	jwtToken, err := jwt.ParseWithClaims(token, claims, keyFunc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !jwtToken.Valid {
+		return nil, nil, fmt.Errorf("jwt token isn't valid")
+	}
+	//tokens without expiration are accepted by jwt, but they were issued before the key was persisted
+	if claims.ExpiresAt == 0 || len(claims.Id) == 0 {
+		return nil, nil, fmt.Errorf("token has no expiration or id")
+	}
+	if claims.Type != tokenType {
+		return nil, nil, fmt.Errorf("%s token is expected", tokenType)
+	}
+
+	revoked, err := store.IsTokenRevoked(claims.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if revoked {
+		return nil, nil, fmt.Errorf("token is revoked")
+	}
+
+	u, err := store.GetUser(claims.UserId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("user of the token isn't found")
+	}
+	if claims.HashPass != passwordFingerprint(u) {
+		return nil, nil, fmt.Errorf("token password hash doesn't match")
+	}
+	return claims, u, nil
+}
+
+// requestToken returns the access token or the API key sent in the Authorization header, or the token of the cookie
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	if cookie, err := r.Cookie("token"); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+func revokeToken(claims *tokenClaims) (bool, error) {
+	return store.RevokeToken(claims.Id, claims.UserId, time.Unix(claims.ExpiresAt, 0).UTC().Format(time.RFC3339))
+}
+
+// RefreshToken exchanges the refresh token for the new pair of tokens, the refresh token can be used only once
+func RefreshToken(w http.ResponseWriter, r *http.Request) {
+	if len(cfg.Password) == 0 {
+		errorMessage(w, http.StatusForbidden, "authentication is disabled")
+		return
+	}
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r.Body); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var pair tokenPair
+	if err := json.Unmarshal(buf.Bytes(), &pair); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	claims, u, err := parseToken(pair.RefreshToken, tokenRefresh)
+	if err != nil {
+		errorMessage(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	//the token revoked by the concurrent refresh after it was parsed is rejected as well
+	revoked, err := revokeToken(claims)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !revoked {
+		errorMessage(w, http.StatusUnauthorized, "token is revoked")
+		return
+	}
+
+	tokens, err := issueTokens(u, claims.grantedRole(u))
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJson(w, http.StatusOK, tokens)
+}
+
+// SignOut revokes the access token of the request and the refresh token passed in the body
+func SignOut(w http.ResponseWriter, r *http.Request) {
+	if len(cfg.Password) == 0 {
+		errorMessage(w, http.StatusForbidden, "authentication is disabled")
+		return
+	}
+	claims, _, err := parseToken(requestToken(r), tokenAccess)
+	if err != nil {
+		errorMessage(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if _, err := revokeToken(claims); err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var pair tokenPair
+	if err := json.NewDecoder(r.Body).Decode(&pair); err == nil && len(pair.RefreshToken) > 0 {
+		refreshClaims, _, err := parseToken(pair.RefreshToken, tokenRefresh)
+		if err == nil && refreshClaims.UserId == claims.UserId {
+			if _, err := revokeToken(refreshClaims); err != nil {
+				errorMessage(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: "token", Value: "", Path: "/", MaxAge: -1})
+	writeJson(w, http.StatusOK, &map[string]any{})
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+)
+
+// GetTrash returns deleted tasks starting from the last deleted one
+func GetTrash(w http.ResponseWriter, r *http.Request) {
+	tasks, err := userRepo(r).GetTrash()
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, &map[string]any{"tasks": tasks})
+}
+
+// RestoreTask returns the task from the trash
+func RestoreTask(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	idInt, err := validateTaskID(id)
+	audit := newAudit(r, actionRestore, idInt)
+	w = auditWriter(w, audit)
+	defer saveAudit(audit, nil)
+
+	if err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if denyTask(w, r, idInt, user.RoleEditor) {
+		return
+	}
+
+	if _, err := auditTask(userRepo(r), audit, func() (int, error) {
+		return 0, userRepo(r).RestoreTask(idInt)
+	}); err != nil {
+		errorMessage(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, &map[string]any{})
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/project"
+	"github.com/OlegShamkeev/go_final_project/internal/storage"
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+)
+
+type contextKey int
+
+const (
+	userKey contextKey = iota
+	apiKeyKey
+	roleKey
+)
+
+var errUserExists = errors.New("user with this login already exists")
+
+type credentials struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// currentUser returns the user authenticated by Auth
+func currentUser(r *http.Request) *user.User {
+	if u, ok := r.Context().Value(userKey).(*user.User); ok {
+		return u
+	}
+	return &user.User{Id: user.DefaultId, Login: user.DefaultLogin}
+}
+
+// currentRole returns the role granted to the request by Auth, without authentication everything is permitted
+func currentRole(r *http.Request) string {
+	if role, ok := r.Context().Value(roleKey).(string); ok {
+		return role
+	}
+	return user.RoleAdmin
+}
+
+func withUser(r *http.Request, u *user.User, role string) *http.Request {
+	ctx := context.WithValue(r.Context(), userKey, u)
+	return r.WithContext(context.WithValue(ctx, roleKey, role))
+}
+
+// userNow returns the current time in the time zone of the current user
+func userNow(r *http.Request) time.Time {
+	u := currentUser(r)
+	//without authentication the user isn't loaded by Auth
+	if _, ok := r.Context().Value(userKey).(*user.User); !ok {
+		if stored, err := store.GetUser(u.Id); err == nil {
+			u = stored
+		}
+	}
+	loc, err := task.LoadZone(u.TimeZone)
+	if err != nil {
+		return time.Now()
+	}
+	return time.Now().In(loc)
+}
+
+// userRepo returns the repository scoped to the current user
+func userRepo(r *http.Request) storage.Repository {
+	return store.ForUser(currentUser(r).Id)
+}
+
+// passwordFingerprint is put to the token, so the token is invalidated by the change of the password
+func passwordFingerprint(u *user.User) string {
+	result := sha256.Sum256([]byte(u.PasswordHash))
+	return hex.EncodeToString(result[:])
+}
+
+// isViewerPassword checks TODO_VIEWER_PASSWORD, it signs in the default user only and only with TODO_PASSWORD set
+func isViewerPassword(u *user.User, password string) bool {
+	if u.Id != user.DefaultId || len(cfg.ViewerPassword) == 0 || len(cfg.Password) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(password), []byte(cfg.ViewerPassword)) == 1
+}
+
+// syncDefaultPassword sets TODO_PASSWORD as the password of the default user
+func syncDefaultPassword() error {
+	u, err := store.GetUser(user.DefaultId)
+	if err != nil {
+		return err
+	}
+	if u.CheckPassword(cfg.Password) {
+		return nil
+	}
+	hash, err := user.HashPassword(cfg.Password)
+	if err != nil {
+		return err
+	}
+	return store.UpdateUserPassword(user.DefaultId, hash)
+}
+
+// Register creates the account, accounts are available only if authentication is enabled by TODO_PASSWORD
+func Register(w http.ResponseWriter, r *http.Request) {
+	if len(cfg.Password) == 0 || !cfg.Registration {
+		errorMessage(w, http.StatusForbidden, "registration is disabled")
+		return
+	}
+
+	var c credentials
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if resultValidate := user.Validate(c.Login, c.Password); resultValidate != "" {
+		errorMessage(w, http.StatusBadRequest, resultValidate)
+		return
+	}
+	hash, err := user.HashPassword(c.Password)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var id int
+	err = store.InTx(func(repo storage.Repository) error {
+		if _, err := repo.GetUserByLogin(c.Login); !errors.Is(err, sql.ErrNoRows) {
+			if err == nil {
+				return errUserExists
+			}
+			return err
+		}
+		id, err = repo.CreateUser(&user.User{Login: c.Login, PasswordHash: hash, Role: user.RoleEditor})
+		if err != nil {
+			return err
+		}
+		_, err = repo.ForUser(id).CreateProject(&project.Project{Name: project.DefaultName, IsDefault: true})
+		return err
+	})
+	if errors.Is(err, errUserExists) {
+		errorMessage(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusCreated, &Result{Id: id})
+}
+
+// GetUsers returns all accounts
+func GetUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := store.GetUsers()
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, &map[string]any{"users": users})
+}
+
+// UpdateUserRole changes the role of the user, the default user stays the administrator
+func UpdateUserRole(w http.ResponseWriter, r *http.Request) {
+	audit := newAudit(r, actionUserRole, 0)
+	w = auditWriter(w, audit)
+	defer saveAudit(audit, nil)
+
+	var u user.User
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !user.ValidRole(u.Role) {
+		errorMessage(w, http.StatusBadRequest, "role should be viewer, editor or admin")
+		return
+	}
+	if u.Id == user.DefaultId && u.Role != user.RoleAdmin {
+		errorMessage(w, http.StatusBadRequest, "role of the default user can't be changed")
+		return
+	}
+
+	before, _ := store.GetUser(u.Id)
+	if err := store.UpdateUserRole(u.Id, u.Role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			errorMessage(w, http.StatusNotFound, "user isn't found")
+			return
+		}
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	after, _ := store.GetUser(u.Id)
+	audit.Diff = auditDiff(before, after)
+
+	writeJson(w, http.StatusOK, &map[string]any{})
+}
+
+// GetCurrentUser returns the account of the current user
+func GetCurrentUser(w http.ResponseWriter, r *http.Request) {
+	u, err := store.GetUser(currentUser(r).Id)
+	if err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, u)
+}
+
+// UpdateCurrentUser sets the time zone of the current user, the empty zone is the local zone of the server
+func UpdateCurrentUser(w http.ResponseWriter, r *http.Request) {
+	var u user.User
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := task.LoadZone(u.TimeZone); err != nil {
+		errorMessage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := store.UpdateUserTimeZone(currentUser(r).Id, u.TimeZone); err != nil {
+		errorMessage(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJson(w, http.StatusOK, &map[string]any{})
+}
@@ -0,0 +1,114 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+)
+
+// Prefix marks API keys, so they are told apart from JWT tokens in the Authorization header
+const Prefix = "todo_"
+
+// ScopeCalendar keys open only the iCalendar feed of the user, they are passed in the query
+// by calendar clients and aren't accepted by the rest of the API
+const (
+	ScopeRead     = "read"
+	ScopeWrite    = "write"
+	ScopeCalendar = "calendar"
+)
+
+const (
+	keyLength     = 32
+	maxNameLength = 64
+	// shownLength is the length of the key beginning kept to recognize the key in the list
+	shownLength = len(Prefix) + 8
+)
+
+// Key is the API key of the user, only the hash of the key is stored.
+// Timestamps are in the time.RFC3339 format of UTC, empty ExpiresAt means the key doesn't expire.
+type Key struct {
+	Id     int    `json:"id" db:"id"`
+	UserId int    `json:"-" db:"user_id"`
+	Name   string `json:"name" db:"name"`
+	Hint   string `json:"hint" db:"hint"`
+	Hash   string `json:"-" db:"key_hash"`
+	Scope  string `json:"scope" db:"scope"`
+	// Role is granted to the key on creation, it's never higher than the role of the creator
+	Role      string `json:"role" db:"role"`
+	CreatedAt string `json:"created_at" db:"created_at"`
+	ExpiresAt string `json:"expires_at,omitempty" db:"expires_at"`
+}
+
+// Generate returns the new key, it's shown to the user once
+func Generate() (string, error) {
+	key := make([]byte, keyLength)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return Prefix + hex.EncodeToString(key), nil
+}
+
+// Hash of the key, the key is random so plain SHA-256 is enough
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func IsKey(token string) bool {
+	return strings.HasPrefix(token, Prefix)
+}
+
+// Assign binds the generated key to the record, only its hash and beginning are kept
+func (k *Key) Assign(key string) {
+	k.Hint = key[:min(shownLength, len(key))] + "..."
+	k.Hash = Hash(key)
+	k.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+}
+
+// Validate checks the fields of the new key, the expiration is normalized to UTC
+func (k *Key) Validate() string {
+	k.Name = strings.TrimSpace(k.Name)
+	if len(k.Name) == 0 || len(k.Name) > maxNameLength {
+		return "name of the key should be from 1 to 64 characters"
+	}
+	if len(k.Scope) == 0 {
+		k.Scope = ScopeRead
+	}
+	if k.Scope != ScopeRead && k.Scope != ScopeWrite && k.Scope != ScopeCalendar {
+		return "scope of the key should be read, write or calendar"
+	}
+	if len(k.ExpiresAt) > 0 {
+		expiresAt, err := time.Parse(time.RFC3339, k.ExpiresAt)
+		if err != nil {
+			return "expires_at should be in RFC 3339 format"
+		}
+		if !expiresAt.After(time.Now()) {
+			return "expires_at should be in the future"
+		}
+		k.ExpiresAt = expiresAt.UTC().Format(time.RFC3339)
+	}
+	return ""
+}
+
+func (k *Key) Expired(now time.Time) bool {
+	return len(k.ExpiresAt) > 0 && k.ExpiresAt <= now.UTC().Format(time.RFC3339)
+}
+
+// Grant sets the role of the key by its scope: the write scope makes the editor and the others the viewer.
+// The role is lowered to the one granted to the creator, e.g. signed in by the viewer password.
+func (k *Key) Grant(creatorRole string) {
+	k.Role = user.RoleViewer
+	if k.Scope == ScopeWrite {
+		k.Role = user.RoleEditor
+	}
+	k.Role = user.LowerRole(k.Role, creatorRole)
+}
+
+// GrantedRole is the role of the key, it's lowered if the owner has lost the role since the key was created
+func (k *Key) GrantedRole(ownerRole string) string {
+	return user.LowerRole(k.Role, ownerRole)
+}
@@ -1,9 +1,55 @@
 package config
 
+import "time"
+
 type Config struct {
-	Port      int    `env:"TODO_PORT" envDefault:"7540"`
-	WebFolder string `envDefault:"./web"`
-	DBPath    string `env:"TODO_DBFILE"`
-	Limit     int    `env:"LIMIT" envDefault:"50"`
-	Password  string `env:"TODO_PASSWORD"`
+	Port        int    `env:"TODO_PORT" envDefault:"7540"`
+	WebFolder   string `envDefault:"./web"`
+	DBDriver    string `env:"TODO_DBDRIVER"`
+	DBPath      string `env:"TODO_DBFILE"`
+	PostgresDSN string `env:"TODO_PG_DSN"`
+	Limit       int    `env:"LIMIT" envDefault:"50"`
+	// Password of the default user, accounts and authentication are enabled only if it is set
+	Password string `env:"TODO_PASSWORD"`
+	// ViewerPassword signs in the default user with the viewer role, the read-only access to its tasks
+	ViewerPassword string `env:"TODO_VIEWER_PASSWORD"`
+	// Registration allows anyone to create the account, it's off by default so the server exposed
+	// to the network isn't open to strangers
+	Registration bool `env:"TODO_REGISTRATION" envDefault:"false"`
+	// JWTKey signs the auth tokens, if it isn't set the key is read from JWTKeyFile or generated there.
+	// JWTKeyFile is jwt.key next to the database file by default.
+	JWTKey     string `env:"TODO_JWT_KEY"`
+	JWTKeyFile string `env:"TODO_JWT_KEY_FILE"`
+	// JWTPreviousKey is the key used before rotation, tokens signed by it are accepted during JWTKeyGrace
+	JWTPreviousKey string        `env:"TODO_JWT_PREVIOUS_KEY"`
+	JWTKeyGrace    time.Duration `env:"TODO_JWT_KEY_GRACE" envDefault:"24h"`
+	// TokenTTL and RefreshTokenTTL are lifetimes of the access and refresh tokens
+	TokenTTL        time.Duration `env:"TODO_TOKEN_TTL" envDefault:"12h"`
+	RefreshTokenTTL time.Duration `env:"TODO_REFRESH_TOKEN_TTL" envDefault:"720h"`
+	// RequireIfMatch rejects the updates and the completions of the tasks without the If-Match header
+	// with the ETag of the task, it can be turned off for the old clients which don't send the header
+	RequireIfMatch bool `env:"TODO_REQUIRE_IF_MATCH" envDefault:"true"`
+	// TrashRetention is how long deleted tasks are kept in the trash before they are purged
+	TrashRetention     time.Duration `env:"TODO_TRASH_RETENTION" envDefault:"720h"`
+	TrashPurgeInterval time.Duration `env:"TODO_TRASH_PURGE_INTERVAL" envDefault:"1h"`
+	// Reminders are the default offsets of the reminders for the tasks without their own ones, like 1d,09:00.
+	// The task without the time is due at ReminderTime of its day. The scheduler runs every ReminderInterval
+	// if any of the SMTP, webhook or Telegram notifiers is configured.
+	Reminders        string        `env:"TODO_REMINDERS"`
+	ReminderTime     string        `env:"TODO_REMINDER_TIME" envDefault:"09:00"`
+	ReminderInterval time.Duration `env:"TODO_REMINDER_INTERVAL" envDefault:"1m"`
+	// SMTPAddr is host:port of the mail server, the reminders are sent from SMTPFrom to SMTPTo,
+	// which could list several comma separated addresses. The plain auth is used if SMTPUser is set.
+	SMTPAddr     string `env:"TODO_SMTP_ADDR"`
+	SMTPUser     string `env:"TODO_SMTP_USER"`
+	SMTPPassword string `env:"TODO_SMTP_PASSWORD"`
+	SMTPFrom     string `env:"TODO_SMTP_FROM"`
+	SMTPTo       string `env:"TODO_SMTP_TO"`
+	// WebhookURL receives the reminders as JSON in POST requests
+	WebhookURL string `env:"TODO_WEBHOOK_URL"`
+	// TelegramToken is the token of the bot which sends the reminders to TelegramChatId,
+	// TelegramAPI is the address of the Bot API server
+	TelegramToken  string `env:"TODO_TELEGRAM_TOKEN"`
+	TelegramChatId string `env:"TODO_TELEGRAM_CHAT_ID"`
+	TelegramAPI    string `env:"TODO_TELEGRAM_API" envDefault:"https://api.telegram.org"`
 }
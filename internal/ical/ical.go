@@ -0,0 +1,234 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// maxLineLength is the limit of the content line length in octets, longer lines are folded
+const maxLineLength = 75
+
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Component is an iCalendar object like VCALENDAR, VEVENT or VTODO
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []Component
+}
+
+// Add appends property, values of TEXT type should be escaped by Text before.
+func (c *Component) Add(name string, value string) {
+	c.Properties = append(c.Properties, Property{Name: name, Value: value})
+}
+
+// AddWithParams appends property with parameters, e.g. DTSTART;VALUE=DATE:20240126
+func (c *Component) AddWithParams(name string, params map[string]string, value string) {
+	c.Properties = append(c.Properties, Property{Name: name, Params: params, Value: value})
+}
+
+// Get returns the first property with the name
+func (c *Component) Get(name string) (Property, bool) {
+	for _, p := range c.Properties {
+		if strings.EqualFold(p.Name, name) {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// Text escapes value of the TEXT type
+func Text(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(s)
+}
+
+// UnescapeText reverts escaping of the TEXT type value
+func UnescapeText(s string) string {
+	replacer := strings.NewReplacer(`\\`, `\`, `\;`, `;`, `\,`, `,`, `\n`, "\n", `\N`, "\n")
+	return replacer.Replace(s)
+}
+
+// Decode reads all top level components, usually it is the only VCALENDAR.
+func Decode(r io.Reader) ([]Component, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Component
+	var stack []*Component
+	for i, line := range lines {
+		p, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		switch strings.ToUpper(p.Name) {
+		case "BEGIN":
+			stack = append(stack, &Component{Name: strings.ToUpper(p.Value)})
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(p.Value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", i+1, p.Value)
+			}
+			c := *stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				result = append(result, c)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, c)
+			}
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("line %d: property %s outside of a component", i+1, p.Name)
+			}
+			c := stack[len(stack)-1]
+			c.Properties = append(c.Properties, p)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("component %s isn't closed", stack[len(stack)-1].Name)
+	}
+	return result, nil
+}
+
+// unfold joins content lines folded by CRLF followed by a space or a tab
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) == 0 {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits content line into name, parameters and value, parameter values could be quoted
+func parseLine(line string) (Property, error) {
+	p := Property{}
+	inQuotes := false
+	nameEnd := -1
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '"':
+			inQuotes = !inQuotes
+		case (c == ';' || c == ':') && !inQuotes && nameEnd < 0:
+			nameEnd = i
+			p.Name = strings.ToUpper(line[:i])
+			if c == ':' {
+				p.Value = line[i+1:]
+				return p, nil
+			}
+		case c == ':' && !inQuotes:
+			params, err := parseParams(line[nameEnd+1 : i])
+			if err != nil {
+				return p, err
+			}
+			p.Params = params
+			p.Value = line[i+1:]
+			return p, nil
+		}
+	}
+	return p, fmt.Errorf("incorrect content line %q", line)
+}
+
+func parseParams(s string) (map[string]string, error) {
+	params := make(map[string]string)
+	for len(s) > 0 {
+		name, rest, found := strings.Cut(s, "=")
+		if !found {
+			return nil, fmt.Errorf("incorrect parameter %q", s)
+		}
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed quote in parameter %q", name)
+			}
+			value = rest[1 : end+1]
+			rest = rest[end+2:]
+		} else if i := strings.Index(rest, ";"); i >= 0 {
+			value, rest = rest[:i], rest[i:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[strings.ToUpper(name)] = value
+		s = strings.TrimPrefix(rest, ";")
+	}
+	return params, nil
+}
+
+// Encode writes component with nested components, lines are separated by CRLF and folded.
+func Encode(w io.Writer, c Component) error {
+	bw := bufio.NewWriter(w)
+	if err := encode(bw, c); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func encode(w *bufio.Writer, c Component) error {
+	if err := writeLine(w, "BEGIN:"+c.Name); err != nil {
+		return err
+	}
+	for _, p := range c.Properties {
+		var line strings.Builder
+		line.WriteString(p.Name)
+		keys := make([]string, 0, len(p.Params))
+		for k := range p.Params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			line.WriteString(";" + k + "=" + p.Params[k])
+		}
+		line.WriteString(":" + p.Value)
+		if err := writeLine(w, line.String()); err != nil {
+			return err
+		}
+	}
+	for _, nested := range c.Components {
+		if err := encode(w, nested); err != nil {
+			return err
+		}
+	}
+	return writeLine(w, "END:"+c.Name)
+}
+
+// writeLine folds the line by maxLineLength octets without splitting of utf-8 sequences
+func writeLine(w *bufio.Writer, line string) error {
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		if _, err := w.WriteString(line[:cut] + "\r\n "); err != nil {
+			return err
+		}
+		line = line[cut:]
+		//continuation lines start with a space, which is counted in the limit
+		limit = maxLineLength - 1
+	}
+	_, err := w.WriteString(line + "\r\n")
+	return err
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
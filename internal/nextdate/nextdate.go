@@ -9,7 +9,33 @@ import (
 
 const dateTimeFormat = "20060102"
 
+// searchLimitYears bounds the search of the next date for rules which could never match, e.g. "m 31 2"
+const searchLimitYears = 100
+
+// weekdayOfMonth is the n-th weekday of a month, negative n counts from the end of the month
+type weekdayOfMonth struct {
+	weekday int
+	n       int
+}
+
+// NextDateAndRepeat works as NextDate and additionally returns the repeat rule which should be stored
+// with the next date. It differs from the passed one only for RRULE with COUNT, as passed occurrences
+// are subtracted from it.
+func NextDateAndRepeat(now time.Time, date string, repeat string, update bool) (string, string, error) {
+	if IsRRule(repeat) {
+		return nextRRuleDate(now, date, repeat, update)
+	}
+	next, err := NextDate(now, date, repeat, update)
+	return next, repeat, err
+}
+
+// NextDate returns the date of the rule following now, the dates are compared in the time zone of now
 func NextDate(now time.Time, date string, repeat string, update bool) (string, error) {
+	if IsRRule(repeat) {
+		next, _, err := nextRRuleDate(now, date, repeat, update)
+		return next, err
+	}
+	now = dateOf(now)
 	d, err := time.Parse(dateTimeFormat, date)
 	if err != nil {
 		return "", err
@@ -19,10 +45,29 @@ func NextDate(now time.Time, date string, repeat string, update bool) (string, e
 	}
 	arrayParams := strings.Split(repeat, " ")
 
+	limit := d
+	if now.After(limit) {
+		limit = now
+	}
+	limit = limit.AddDate(searchLimitYears, 0, 0)
+
 	switch arrayParams[0] {
 	case "y":
+		yearsToAdd := 1
+		if len(arrayParams) > 2 {
+			return "", fmt.Errorf("incorrect format of the repeat parameter")
+		}
+		if len(arrayParams) == 2 {
+			yearsToAdd, err = strconv.Atoi(arrayParams[1])
+			if err != nil || yearsToAdd < 1 {
+				return "", fmt.Errorf("incorrect format of the repeat parameter")
+			}
+			if yearsToAdd > searchLimitYears {
+				return "", fmt.Errorf("years to add more than %d", searchLimitYears)
+			}
+		}
 		for {
-			d = d.AddDate(1, 0, 0)
+			d = d.AddDate(yearsToAdd, 0, 0)
 			if d.After(now) {
 				break
 			}
@@ -40,7 +85,7 @@ func NextDate(now time.Time, date string, repeat string, update bool) (string, e
 			return "", fmt.Errorf("days to add more than 400")
 		}
 		//check if date has come is in today or in future
-		if (date == time.Now().Format(dateTimeFormat) || d.After(time.Now())) && !update {
+		if (date == now.Format(dateTimeFormat) || d.After(now)) && !update {
 			return date, nil
 		}
 		for {
@@ -67,16 +112,40 @@ func NextDate(now time.Time, date string, repeat string, update bool) (string, e
 			daysMap[day]++
 		}
 
+		weeksInterval := 1
+		if len(arrayParams) > 3 {
+			return "", fmt.Errorf("incorrect format of the repeat parameter")
+		}
+		if len(arrayParams) == 3 {
+			interval, found := strings.CutPrefix(arrayParams[2], "/")
+			if !found {
+				return "", fmt.Errorf("incorrect format of the repeat parameter")
+			}
+			weeksInterval, err = strconv.Atoi(interval)
+			if err != nil || weeksInterval < 1 || weeksInterval > 52 {
+				return "", fmt.Errorf("incorrect format of the repeat parameter")
+			}
+		}
+		//weeks are counted from the monday of the week of the initial date
+		firstMonday := startOfWeek(d)
+		inWeek := func(t time.Time) bool {
+			weeks := int(startOfWeek(t).Sub(firstMonday).Hours()) / (24 * 7)
+			return weeks%weeksInterval == 0
+		}
+
 		//check if date has come is in today or in future and suitable for repeat rules
 		_, ok := daysMap[int(d.Weekday())]
-		if (date == time.Now().Format(dateTimeFormat) || d.After(time.Now())) && ok && !update {
+		if (date == now.Format(dateTimeFormat) || d.After(now)) && ok && !update {
 			return date, nil
 		}
 		for {
 			d = d.AddDate(0, 0, 1)
-			if _, ok := daysMap[int(d.Weekday())]; d.After(now) && ok {
+			if _, ok := daysMap[int(d.Weekday())]; d.After(now) && ok && inWeek(d) {
 				break
 			}
+			if d.After(limit) {
+				return "", fmt.Errorf("no date matches the repeat parameter")
+			}
 		}
 		return d.Format(dateTimeFormat), nil
 	case "m":
@@ -85,8 +154,17 @@ func NextDate(now time.Time, date string, repeat string, update bool) (string, e
 		}
 
 		daysMap := make(map[int]int)
+		weekdaysMap := make(map[weekdayOfMonth]int)
 		days := strings.Split(arrayParams[1], ",")
 		for _, i := range days {
+			if strings.Contains(i, "#") {
+				wd, err := parseWeekdayOfMonth(i)
+				if err != nil {
+					return "", err
+				}
+				weekdaysMap[wd]++
+				continue
+			}
 			day, err := strconv.Atoi(i)
 			if day > 31 || day < -2 || err != nil {
 				return "", fmt.Errorf("incorrect format of the repeat parameter")
@@ -121,12 +199,47 @@ func NextDate(now time.Time, date string, repeat string, update bool) (string, e
 
 			_, ok2 := daysMap[backwardKey]
 
-			if _, ok3 := monthsMap[int(d.Month())]; (ok1 || ok2) && d.After(now) && ok3 {
+			_, ok4 := weekdaysMap[weekdayOfMonth{weekday: int(d.Weekday()), n: (d.Day()-1)/7 + 1}]
+			_, ok5 := weekdaysMap[weekdayOfMonth{weekday: int(d.Weekday()), n: -((daysInMonth-d.Day())/7 + 1)}]
+
+			if _, ok3 := monthsMap[int(d.Month())]; (ok1 || ok2 || ok4 || ok5) && d.After(now) && ok3 {
 				break
 			}
+			if d.After(limit) {
+				return "", fmt.Errorf("no date matches the repeat parameter")
+			}
 		}
 		return d.Format(dateTimeFormat), nil
 	default:
 		return "", fmt.Errorf("incorrect format of the Repeat parameter")
 	}
 }
+
+// parseWeekdayOfMonth parses "weekday#n" day of the "m" rule, e.g. 2#2 is the second tuesday
+// and 5#-1 is the last friday of a month. Weekdays are numbered 1-7 starting from monday.
+func parseWeekdayOfMonth(s string) (weekdayOfMonth, error) {
+	weekdayRaw, nRaw, _ := strings.Cut(s, "#")
+	weekday, err := strconv.Atoi(weekdayRaw)
+	if err != nil || weekday < 1 || weekday > 7 {
+		return weekdayOfMonth{}, fmt.Errorf("incorrect format of the repeat parameter")
+	}
+	n, err := strconv.Atoi(nRaw)
+	if err != nil || n == 0 || n > 5 || n < -5 {
+		return weekdayOfMonth{}, fmt.Errorf("incorrect format of the repeat parameter")
+	}
+	if weekday == 7 {
+		weekday = 0
+	}
+	return weekdayOfMonth{weekday: weekday, n: n}, nil
+}
+
+// dateOf returns the date of t in its time zone as the midnight of UTC, the dates of the rules are parsed that way
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// startOfWeek returns monday of the week the date belongs to
+func startOfWeek(d time.Time) time.Time {
+	offset := (int(d.Weekday()) + 6) % 7
+	return time.Date(d.Year(), d.Month(), d.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
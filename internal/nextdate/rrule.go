@@ -0,0 +1,627 @@
+package nextdate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoMoreOccurrences is returned when COUNT or UNTIL of the rule doesn't allow any further date.
+var ErrNoMoreOccurrences = errors.New("no more occurrences of the repeat rule")
+
+var errNotExpressible = errors.New("repeat rule can't be expressed in the compact format")
+
+const (
+	freqDaily   = "DAILY"
+	freqWeekly  = "WEEKLY"
+	freqMonthly = "MONTHLY"
+	freqYearly  = "YEARLY"
+)
+
+// weekday codes of RFC 5545 in the order of time.Weekday
+var weekdayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// weekdayNum is an element of the BYDAY rule part, zero n means every such weekday of the period
+type weekdayNum struct {
+	n       int
+	weekday time.Weekday
+}
+
+// RRule is a subset of RFC 5545 recurrence rule working with dates only,
+// time based parts (BYHOUR, BYMINUTE, BYSECOND) aren't supported.
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      string
+	ByDay      []weekdayNum
+	ByMonthDay []int
+	ByMonth    []int
+	BySetPos   []int
+	WeekStart  time.Weekday
+}
+
+// IsRRule reports whether repeat is written as RFC 5545 RRULE instead of the compact d/w/m/y format.
+func IsRRule(repeat string) bool {
+	upper := strings.ToUpper(strings.TrimSpace(repeat))
+	return strings.HasPrefix(upper, "RRULE:") || strings.HasPrefix(upper, "FREQ=")
+}
+
+// ParseRRule parses rule value with optional "RRULE:" prefix, e.g. FREQ=WEEKLY;BYDAY=MO,WE;INTERVAL=2
+func ParseRRule(s string) (*RRule, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 6 && strings.EqualFold(s[:6], "RRULE:") {
+		s = s[6:]
+	}
+	rule := &RRule{Interval: 1, WeekStart: time.Monday}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(s, ";") {
+		if len(part) == 0 {
+			continue
+		}
+		key, value, found := strings.Cut(part, "=")
+		key = strings.ToUpper(key)
+		value = strings.ToUpper(value)
+		if !found || len(value) == 0 {
+			return nil, fmt.Errorf("incorrect format of the RRULE part %q", part)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate RRULE part %s", key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch value {
+			case freqDaily, freqWeekly, freqMonthly, freqYearly:
+				rule.Freq = value
+			default:
+				return nil, fmt.Errorf("unsupported RRULE frequency %s", value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(value)
+			if err != nil || rule.Interval < 1 || rule.Interval > 1000 {
+				return nil, fmt.Errorf("incorrect RRULE interval %s", value)
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(value)
+			if err != nil || rule.Count < 1 {
+				return nil, fmt.Errorf("incorrect RRULE count %s", value)
+			}
+		case "UNTIL":
+			if len(value) < 8 {
+				return nil, fmt.Errorf("incorrect RRULE until %s", value)
+			}
+			if _, err := time.Parse(dateTimeFormat, value[:8]); err != nil {
+				return nil, fmt.Errorf("incorrect RRULE until %s", value)
+			}
+			rule.Until = value[:8]
+		case "BYDAY":
+			for _, v := range strings.Split(value, ",") {
+				wn, err := parseWeekdayNum(v)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, wn)
+			}
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseIntList(value, -31, 31)
+			if err != nil {
+				return nil, fmt.Errorf("incorrect RRULE bymonthday %s", value)
+			}
+		case "BYMONTH":
+			rule.ByMonth, err = parseIntList(value, 1, 12)
+			if err != nil {
+				return nil, fmt.Errorf("incorrect RRULE bymonth %s", value)
+			}
+		case "BYSETPOS":
+			rule.BySetPos, err = parseIntList(value, -366, 366)
+			if err != nil {
+				return nil, fmt.Errorf("incorrect RRULE bysetpos %s", value)
+			}
+		case "WKST":
+			wd, ok := weekdayByCode(value)
+			if !ok {
+				return nil, fmt.Errorf("incorrect RRULE wkst %s", value)
+			}
+			rule.WeekStart = wd
+		default:
+			return nil, fmt.Errorf("unsupported RRULE part %s", key)
+		}
+	}
+
+	if len(rule.Freq) == 0 {
+		return nil, fmt.Errorf("RRULE frequency is required")
+	}
+	if rule.Count > 0 && len(rule.Until) > 0 {
+		return nil, fmt.Errorf("RRULE count and until couldn't be used together")
+	}
+	if rule.Freq == freqWeekly && len(rule.ByMonthDay) > 0 {
+		return nil, fmt.Errorf("RRULE bymonthday couldn't be used with weekly frequency")
+	}
+	for _, wn := range rule.ByDay {
+		if wn.n == 0 {
+			continue
+		}
+		switch {
+		case rule.Freq != freqMonthly && rule.Freq != freqYearly:
+			return nil, fmt.Errorf("RRULE byday with position is allowed only for monthly and yearly frequency")
+		case rule.Freq == freqMonthly || len(rule.ByMonth) > 0:
+			if wn.n > 5 || wn.n < -5 {
+				return nil, fmt.Errorf("incorrect RRULE byday position %d", wn.n)
+			}
+		}
+	}
+	if len(rule.BySetPos) > 0 && len(rule.ByDay) == 0 && len(rule.ByMonthDay) == 0 && len(rule.ByMonth) == 0 {
+		return nil, fmt.Errorf("RRULE bysetpos requires another BYxxx rule part")
+	}
+	return rule, nil
+}
+
+func (r *RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if len(r.Until) > 0 {
+		parts = append(parts, "UNTIL="+r.Until)
+	}
+	if len(r.ByMonth) > 0 {
+		parts = append(parts, "BYMONTH="+joinInts(r.ByMonth))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wn := range r.ByDay {
+			day := weekdayCodes[wn.weekday]
+			if wn.n != 0 {
+				day = strconv.Itoa(wn.n) + day
+			}
+			days = append(days, day)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.BySetPos) > 0 {
+		parts = append(parts, "BYSETPOS="+joinInts(r.BySetPos))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayCodes[r.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+// nextRRuleDate finds the next occurrence of the rule with the series started at date.
+// The returned rule has COUNT decreased by the number of passed occurrences.
+func nextRRuleDate(now time.Time, date string, repeat string, update bool) (string, string, error) {
+	now = dateOf(now)
+	start, err := time.Parse(dateTimeFormat, date)
+	if err != nil {
+		return "", "", err
+	}
+	rule, err := ParseRRule(repeat)
+	if err != nil {
+		return "", "", err
+	}
+	if len(rule.Until) > 0 && date > rule.Until {
+		return "", "", ErrNoMoreOccurrences
+	}
+
+	//check if date has come is in today or in future, the start of the series is always its occurrence
+	if (date == now.Format(dateTimeFormat) || start.After(now)) && !update {
+		return date, repeat, nil
+	}
+
+	limit := start
+	if now.After(limit) {
+		limit = now
+	}
+	limit = limit.AddDate(searchLimitYears, 0, 0)
+
+	index := 0
+	for period := 0; ; period++ {
+		periodStart := rule.periodStart(start, period*rule.Interval)
+		if periodStart.After(limit) {
+			return "", "", fmt.Errorf("no date matches the repeat parameter")
+		}
+		for _, d := range rule.expand(periodStart, start) {
+			if !d.After(start) {
+				continue
+			}
+			if len(rule.Until) > 0 && d.Format(dateTimeFormat) > rule.Until {
+				return "", "", ErrNoMoreOccurrences
+			}
+			index++
+			if rule.Count > 0 && index >= rule.Count {
+				return "", "", ErrNoMoreOccurrences
+			}
+			if d.After(now) {
+				if rule.Count > 0 {
+					rule.Count -= index
+					repeat = rule.String()
+				}
+				return d.Format(dateTimeFormat), repeat, nil
+			}
+		}
+	}
+}
+
+// periodStart returns the first day of the n-th period after the period of the start date
+func (r *RRule) periodStart(start time.Time, n int) time.Time {
+	switch r.Freq {
+	case freqDaily:
+		return start.AddDate(0, 0, n)
+	case freqWeekly:
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		return start.AddDate(0, 0, 7*n-offset)
+	case freqMonthly:
+		return time.Date(start.Year(), start.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(start.Year()+n, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// expand returns sorted candidate dates of the period limited by BYxxx rule parts and BYSETPOS
+func (r *RRule) expand(periodStart time.Time, start time.Time) []time.Time {
+	var candidates []time.Time
+
+	switch r.Freq {
+	case freqDaily:
+		if r.matchMonth(periodStart) && r.matchMonthDay(periodStart) && r.matchDay(periodStart, periodStart, periodStart) {
+			candidates = append(candidates, periodStart)
+		}
+	case freqWeekly:
+		for i := 0; i < 7; i++ {
+			d := periodStart.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 && d.Weekday() != start.Weekday() {
+				continue
+			}
+			if r.matchMonth(d) && r.matchDay(d, d, d) {
+				candidates = append(candidates, d)
+			}
+		}
+	case freqMonthly:
+		if !r.matchMonth(periodStart) {
+			break
+		}
+		candidates = r.expandMonth(periodStart, start)
+	case freqYearly:
+		switch {
+		case len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0 && len(r.ByDay) == 0:
+			d := time.Date(periodStart.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+			if d.Day() == start.Day() {
+				candidates = append(candidates, d)
+			}
+		case len(r.ByMonth) > 0:
+			for month := time.January; month <= time.December; month++ {
+				monthStart := time.Date(periodStart.Year(), month, 1, 0, 0, 0, 0, time.UTC)
+				if r.matchMonth(monthStart) {
+					candidates = append(candidates, r.expandMonth(monthStart, start)...)
+				}
+			}
+		default:
+			yearEnd := periodStart.AddDate(1, 0, -1)
+			for d := periodStart; !d.After(yearEnd); d = d.AddDate(0, 0, 1) {
+				if r.matchMonthDay(d) && r.matchDay(d, periodStart, yearEnd) {
+					candidates = append(candidates, d)
+				}
+			}
+		}
+	}
+
+	if len(r.BySetPos) == 0 || len(candidates) == 0 {
+		return candidates
+	}
+	selected := make(map[int]bool)
+	for _, pos := range r.BySetPos {
+		i := pos - 1
+		if pos < 0 {
+			i = len(candidates) + pos
+		}
+		if i >= 0 && i < len(candidates) {
+			selected[i] = true
+		}
+	}
+	result := make([]time.Time, 0, len(selected))
+	for i, d := range candidates {
+		if selected[i] {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// expandMonth returns days of the month matching BYMONTHDAY and BYDAY,
+// without both of them the day of month of the start date is used
+func (r *RRule) expandMonth(monthStart time.Time, start time.Time) []time.Time {
+	var candidates []time.Time
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		d := time.Date(monthStart.Year(), monthStart.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		if d.Day() == start.Day() {
+			candidates = append(candidates, d)
+		}
+		return candidates
+	}
+	monthEnd := monthStart.AddDate(0, 1, -1)
+	for d := monthStart; !d.After(monthEnd); d = d.AddDate(0, 0, 1) {
+		if r.matchMonthDay(d) && r.matchDay(d, monthStart, monthEnd) {
+			candidates = append(candidates, d)
+		}
+	}
+	return candidates
+}
+
+func (r *RRule) matchMonth(d time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if time.Month(m) == d.Month() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) matchMonthDay(d time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	daysInMonth := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, md := range r.ByMonthDay {
+		if md == d.Day() || (md < 0 && daysInMonth+md+1 == d.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDay checks BYDAY, positions are counted inside of the scope from scopeStart to scopeEnd
+func (r *RRule) matchDay(d time.Time, scopeStart time.Time, scopeEnd time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wn := range r.ByDay {
+		if wn.weekday != d.Weekday() {
+			continue
+		}
+		switch {
+		case wn.n == 0:
+			return true
+		case wn.n > 0 && daysBetween(scopeStart, d)/7+1 == wn.n:
+			return true
+		case wn.n < 0 && daysBetween(d, scopeEnd)/7+1 == -wn.n:
+			return true
+		}
+	}
+	return false
+}
+
+// ToRRule converts repeat rule of the compact d/w/m/y format to RRULE.
+func ToRRule(repeat string) (string, error) {
+	if IsRRule(repeat) {
+		rule, err := ParseRRule(repeat)
+		if err != nil {
+			return "", err
+		}
+		return rule.String(), nil
+	}
+
+	arrayParams := strings.Split(repeat, " ")
+	rule := &RRule{Interval: 1, WeekStart: time.Monday}
+	var err error
+
+	switch arrayParams[0] {
+	case "d":
+		if len(arrayParams) != 2 {
+			return "", fmt.Errorf("incorrect format of the repeat parameter")
+		}
+		rule.Freq = freqDaily
+		rule.Interval, err = strconv.Atoi(arrayParams[1])
+		if err != nil || rule.Interval < 1 || rule.Interval > 400 {
+			return "", fmt.Errorf("incorrect format of the repeat parameter")
+		}
+	case "y":
+		if len(arrayParams) > 2 {
+			return "", fmt.Errorf("incorrect format of the repeat parameter")
+		}
+		rule.Freq = freqYearly
+		if len(arrayParams) == 2 {
+			rule.Interval, err = strconv.Atoi(arrayParams[1])
+			if err != nil || rule.Interval < 1 || rule.Interval > searchLimitYears {
+				return "", fmt.Errorf("incorrect format of the repeat parameter")
+			}
+		}
+	case "w":
+		if len(arrayParams) < 2 || len(arrayParams) > 3 {
+			return "", fmt.Errorf("incorrect format of the repeat parameter")
+		}
+		rule.Freq = freqWeekly
+		days, err := parseIntList(arrayParams[1], 1, 7)
+		if err != nil {
+			return "", fmt.Errorf("incorrect format of the repeat parameter")
+		}
+		for _, day := range days {
+			rule.ByDay = append(rule.ByDay, weekdayNum{weekday: time.Weekday(day % 7)})
+		}
+		if len(arrayParams) == 3 {
+			interval, found := strings.CutPrefix(arrayParams[2], "/")
+			if !found {
+				return "", fmt.Errorf("incorrect format of the repeat parameter")
+			}
+			rule.Interval, err = strconv.Atoi(interval)
+			if err != nil || rule.Interval < 1 || rule.Interval > 52 {
+				return "", fmt.Errorf("incorrect format of the repeat parameter")
+			}
+		}
+	case "m":
+		if len(arrayParams) < 2 || len(arrayParams) > 3 {
+			return "", fmt.Errorf("incorrect format of the repeat parameter")
+		}
+		rule.Freq = freqMonthly
+		for _, i := range strings.Split(arrayParams[1], ",") {
+			if strings.Contains(i, "#") {
+				wd, err := parseWeekdayOfMonth(i)
+				if err != nil {
+					return "", err
+				}
+				rule.ByDay = append(rule.ByDay, weekdayNum{n: wd.n, weekday: time.Weekday(wd.weekday)})
+				continue
+			}
+			day, err := strconv.Atoi(i)
+			if day > 31 || day < -2 || day == 0 || err != nil {
+				return "", fmt.Errorf("incorrect format of the repeat parameter")
+			}
+			rule.ByMonthDay = append(rule.ByMonthDay, day)
+		}
+		//days of month and weekdays are joined in the compact format but intersected in RRULE
+		if len(rule.ByDay) > 0 && len(rule.ByMonthDay) > 0 {
+			return "", errNotExpressible
+		}
+		if len(arrayParams) == 3 {
+			rule.ByMonth, err = parseIntList(arrayParams[2], 1, 12)
+			if err != nil {
+				return "", fmt.Errorf("incorrect format of the repeat parameter")
+			}
+		}
+	default:
+		return "", fmt.Errorf("incorrect format of the Repeat parameter")
+	}
+	return rule.String(), nil
+}
+
+// FromRRule converts RRULE to the compact d/w/m/y format if the rule could be expressed by it.
+func FromRRule(repeat string) (string, error) {
+	if !IsRRule(repeat) {
+		return repeat, nil
+	}
+	rule, err := ParseRRule(repeat)
+	if err != nil {
+		return "", err
+	}
+	if rule.Count > 0 || len(rule.Until) > 0 || len(rule.BySetPos) > 0 {
+		return "", errNotExpressible
+	}
+
+	switch rule.Freq {
+	case freqDaily:
+		if len(rule.ByDay) > 0 || len(rule.ByMonthDay) > 0 || len(rule.ByMonth) > 0 || rule.Interval > 400 {
+			return "", errNotExpressible
+		}
+		return "d " + strconv.Itoa(rule.Interval), nil
+	case freqWeekly:
+		if len(rule.ByDay) == 0 || len(rule.ByMonth) > 0 || rule.Interval > 52 ||
+			(rule.Interval > 1 && rule.WeekStart != time.Monday) {
+			return "", errNotExpressible
+		}
+		days := make([]int, 0, len(rule.ByDay))
+		for _, wn := range rule.ByDay {
+			days = append(days, compactWeekday(wn.weekday))
+		}
+		sort.Ints(days)
+		result := "w " + joinInts(days)
+		if rule.Interval > 1 {
+			result += " /" + strconv.Itoa(rule.Interval)
+		}
+		return result, nil
+	case freqMonthly:
+		if rule.Interval > 1 || (len(rule.ByDay) > 0) == (len(rule.ByMonthDay) > 0) {
+			return "", errNotExpressible
+		}
+		days := make([]string, 0, len(rule.ByDay)+len(rule.ByMonthDay))
+		for _, md := range rule.ByMonthDay {
+			if md < -2 {
+				return "", errNotExpressible
+			}
+			days = append(days, strconv.Itoa(md))
+		}
+		for _, wn := range rule.ByDay {
+			if wn.n == 0 {
+				return "", errNotExpressible
+			}
+			days = append(days, fmt.Sprintf("%d#%d", compactWeekday(wn.weekday), wn.n))
+		}
+		result := "m " + strings.Join(days, ",")
+		if len(rule.ByMonth) > 0 {
+			result += " " + joinInts(rule.ByMonth)
+		}
+		return result, nil
+	default:
+		if len(rule.ByDay) > 0 || len(rule.ByMonthDay) > 0 || len(rule.ByMonth) > 0 || rule.Interval > searchLimitYears {
+			return "", errNotExpressible
+		}
+		if rule.Interval > 1 {
+			return "y " + strconv.Itoa(rule.Interval), nil
+		}
+		return "y", nil
+	}
+}
+
+func parseWeekdayNum(s string) (weekdayNum, error) {
+	if len(s) < 2 {
+		return weekdayNum{}, fmt.Errorf("incorrect RRULE byday %s", s)
+	}
+	wd, ok := weekdayByCode(s[len(s)-2:])
+	if !ok {
+		return weekdayNum{}, fmt.Errorf("incorrect RRULE byday %s", s)
+	}
+	wn := weekdayNum{weekday: wd}
+	if len(s) > 2 {
+		n, err := strconv.Atoi(s[:len(s)-2])
+		if err != nil || n == 0 || n > 53 || n < -53 {
+			return weekdayNum{}, fmt.Errorf("incorrect RRULE byday %s", s)
+		}
+		wn.n = n
+	}
+	return wn, nil
+}
+
+func weekdayByCode(code string) (time.Weekday, bool) {
+	for i, c := range weekdayCodes {
+		if c == code {
+			return time.Weekday(i), true
+		}
+	}
+	return 0, false
+}
+
+// compactWeekday converts time.Weekday to 1-7 numbering of the compact format
+func compactWeekday(wd time.Weekday) int {
+	if wd == time.Sunday {
+		return 7
+	}
+	return int(wd)
+}
+
+func parseIntList(s string, min int, max int) ([]int, error) {
+	var result []int
+	for _, v := range strings.Split(s, ",") {
+		i, err := strconv.Atoi(v)
+		if err != nil || i == 0 || i < min || i > max {
+			return nil, fmt.Errorf("incorrect value %s", v)
+		}
+		result = append(result, i)
+	}
+	sort.Ints(result)
+	return result, nil
+}
+
+func joinInts(values []int) string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		result = append(result, strconv.Itoa(v))
+	}
+	return strings.Join(result, ",")
+}
+
+func daysBetween(from time.Time, to time.Time) int {
+	return int(to.Sub(from).Hours()) / 24
+}
@@ -0,0 +1,39 @@
+package project
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultName is the name of the project created for every user, the tasks made before projects
+// were added belong to the default project of their owner
+const DefaultName = "Default"
+
+const maxNameLength = 128
+
+// Project is the list of tasks shared by its members. Role is the role of the current user in the project,
+// the members have the same viewer, editor and admin roles as the users.
+type Project struct {
+	Id        int    `json:"id" db:"id"`
+	Name      string `json:"name" db:"name"`
+	OwnerId   int    `json:"owner_id" db:"owner_id"`
+	IsDefault bool   `json:"is_default" db:"is_default"`
+	CreatedAt string `json:"created_at" db:"created_at"`
+	Role      string `json:"role,omitempty" db:"role"`
+}
+
+type Member struct {
+	ProjectId int    `json:"project_id" db:"project_id"`
+	UserId    int    `json:"user_id" db:"user_id"`
+	Login     string `json:"login" db:"login"`
+	Role      string `json:"role" db:"role"`
+}
+
+// Validate checks the name of the project
+func (p *Project) Validate() string {
+	p.Name = strings.TrimSpace(p.Name)
+	if len(p.Name) == 0 || utf8.RuneCountInString(p.Name) > maxNameLength {
+		return "name of the project should be from 1 to 128 characters"
+	}
+	return ""
+}
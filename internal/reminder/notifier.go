@@ -0,0 +1,238 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/config"
+)
+
+// Channels of the notifiers, they are kept in the delivery state of the reminders
+const (
+	ChannelEmail    = "email"
+	ChannelWebhook  = "webhook"
+	ChannelTelegram = "telegram"
+)
+
+// Reminder is the message about the task, it's sent to the webhook as JSON.
+// DueAt and RemindAt are in the time.RFC3339 format of the time zone of the task.
+type Reminder struct {
+	TaskId   string `json:"task_id"`
+	Title    string `json:"title"`
+	Comment  string `json:"comment,omitempty"`
+	Date     string `json:"date"`
+	Time     string `json:"time,omitempty"`
+	TimeZone string `json:"timezone"`
+	Login    string `json:"login"`
+	DueAt    string `json:"due_at"`
+	RemindAt string `json:"remind_at"`
+}
+
+// Subject is the short text of the reminder
+func (r Reminder) Subject() string {
+	return "Reminder: " + r.Title
+}
+
+// Text is the full text of the reminder
+func (r Reminder) Text() string {
+	due := r.Date[6:8] + "." + r.Date[4:6] + "." + r.Date[:4]
+	if len(r.Time) > 0 {
+		due += " " + r.Time
+	}
+	text := fmt.Sprintf("%s\nDue: %s (%s)", r.Title, due, r.TimeZone)
+	if len(r.Comment) > 0 {
+		text += "\n\n" + r.Comment
+	}
+	return text
+}
+
+// Notifier delivers the reminders through its channel
+type Notifier interface {
+	Channel() string
+	Notify(ctx context.Context, r Reminder) error
+}
+
+// NewNotifiers returns the notifiers configured in config.Config
+func NewNotifiers(config *config.Config) []Notifier {
+	notifiers := []Notifier{}
+	if len(config.SMTPAddr) > 0 {
+		notifiers = append(notifiers, &SMTPNotifier{
+			Addr:     config.SMTPAddr,
+			User:     config.SMTPUser,
+			Password: config.SMTPPassword,
+			From:     config.SMTPFrom,
+			To:       strings.Split(config.SMTPTo, ","),
+		})
+	}
+	if len(config.WebhookURL) > 0 {
+		notifiers = append(notifiers, &WebhookNotifier{URL: config.WebhookURL})
+	}
+	if len(config.TelegramToken) > 0 {
+		notifiers = append(notifiers, &TelegramNotifier{
+			API:    config.TelegramAPI,
+			Token:  config.TelegramToken,
+			ChatId: config.TelegramChatId,
+		})
+	}
+	return notifiers
+}
+
+// SMTPNotifier sends the reminders by email, STARTTLS is used if the server supports it
+type SMTPNotifier struct {
+	Addr     string
+	User     string
+	Password string
+	From     string
+	To       []string
+}
+
+func (n *SMTPNotifier) Channel() string {
+	return ChannelEmail
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, r Reminder) error {
+	host, _, err := net.SplitHostPort(n.Addr)
+	if err != nil {
+		return err
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if len(n.User) > 0 {
+		if err := c.Auth(smtp.PlainAuth("", n.User, n.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(n.From); err != nil {
+		return err
+	}
+	for _, to := range n.To {
+		if err := c.Rcpt(strings.TrimSpace(to)); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.message(r)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message returns the email with the headers, the lines are ended by CRLF as SMTP requires
+func (n *SMTPNotifier) message(r Reminder) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", n.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", r.Subject()))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(r.Text(), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// WebhookNotifier posts the reminders as JSON to the URL, any status except of 2xx is the error
+type WebhookNotifier struct {
+	URL string
+}
+
+func (n *WebhookNotifier) Channel() string {
+	return ChannelWebhook
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, r Reminder) error {
+	_, err := postJSON(ctx, n.URL, r)
+	return err
+}
+
+// TelegramNotifier sends the reminders to the chat by the bot through the Bot API
+type TelegramNotifier struct {
+	API    string
+	Token  string
+	ChatId string
+}
+
+func (n *TelegramNotifier) Channel() string {
+	return ChannelTelegram
+}
+
+func (n *TelegramNotifier) Notify(ctx context.Context, r Reminder) error {
+	url := strings.TrimRight(n.API, "/") + "/bot" + n.Token + "/sendMessage"
+	body, err := postJSON(ctx, url, map[string]string{"chat_id": n.ChatId, "text": r.Text()})
+	if err != nil {
+		//the url holds the token of the bot, so it isn't written to the log with the error
+		return fmt.Errorf("telegram: %s", strings.ReplaceAll(err.Error(), n.Token, "***"))
+	}
+	var result struct {
+		Ok          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return err
+	}
+	if !result.Ok {
+		return fmt.Errorf("telegram: %s", result.Description)
+	}
+	return nil
+}
+
+// postJSON posts the data as JSON and returns the body of the response
+func postJSON(ctx context.Context, url string, data any) ([]byte, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s responded with %s", url, resp.Status)
+	}
+	return body, nil
+}
@@ -0,0 +1,193 @@
+package reminder
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/config"
+	"github.com/OlegShamkeev/go_final_project/internal/storage"
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+)
+
+const dateFormat = "20060102"
+
+// maxAttempts limits the deliveries of the reminder through the failing channel, one attempt is made every run
+const maxAttempts = 5
+
+const notifyTimeout = 30 * time.Second
+
+// Scheduler sends the reminders of the tasks through the notifiers. The channels of the notifiers are configured
+// for the server rather than for the users, so only the reminders of the default user are sent. The reminder is sent
+// when its moment has come until the end of the day of its task, so the reminders missed while the server
+// was stopped are sent after the start. The delivery is saved after the reminder is sent to the channel.
+type Scheduler struct {
+	repo      storage.Repository
+	notifiers []Notifier
+	defaults  []task.ReminderOffset
+	dayTime   string
+}
+
+// NewScheduler returns the scheduler with the notifiers and the default reminders configured in config.Config
+func NewScheduler(repo storage.Repository, config *config.Config) (*Scheduler, error) {
+	defaults, err := task.ParseReminders(config.Reminders)
+	if err != nil {
+		return nil, fmt.Errorf("TODO_REMINDERS: %w", err)
+	}
+	dayTime, err := time.Parse(task.TimeFormat, config.ReminderTime)
+	if err != nil {
+		return nil, fmt.Errorf("TODO_REMINDER_TIME should be in the 15:04 format")
+	}
+	return &Scheduler{
+		repo:      repo,
+		notifiers: NewNotifiers(config),
+		defaults:  defaults,
+		dayTime:   dayTime.Format(task.TimeFormat),
+	}, nil
+}
+
+// Enabled checks that there is any notifier to send the reminders through
+func (s *Scheduler) Enabled() bool {
+	return len(s.notifiers) > 0
+}
+
+// Run sends the reminders right away and then every interval.
+// It blocks, so should be started in a separate goroutine.
+func (s *Scheduler) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		sent, err := s.Send(context.Background(), time.Now())
+		if err != nil {
+			log.Printf("Error during sending of reminders: %s\n", err.Error())
+		} else if sent > 0 {
+			log.Printf("Sent %d reminder(s)\n", sent)
+		}
+		<-ticker.C
+	}
+}
+
+// Send delivers the reminders due at now which haven't been delivered yet and returns the number of them
+func (s *Scheduler) Send(ctx context.Context, now time.Time) (int, error) {
+	//the dates cover the tasks which could have the reminder due now in any time zone
+	from := now.AddDate(0, 0, -2).Format(dateFormat)
+	to := now.Add(task.MaxReminderOffset).AddDate(0, 0, 2).Format(dateFormat)
+	tasks, err := s.repo.GetReminderTasks(from, to)
+	if err != nil {
+		return 0, err
+	}
+	users, err := s.repo.GetUsers()
+	if err != nil {
+		return 0, err
+	}
+	usersById := make(map[int]user.User, len(users))
+	for _, u := range users {
+		usersById[u.Id] = u
+	}
+
+	sent := 0
+	for _, t := range tasks {
+		//the other users don't have their own channels, their tasks mustn't reach the channels of the server
+		if t.UserId != user.DefaultId {
+			continue
+		}
+		u := usersById[t.UserId]
+		loc, err := task.LoadZone(cmp.Or(t.TimeZone, u.TimeZone))
+		if err != nil {
+			log.Printf("Reminders of the task %s are skipped: %s\n", t.Id, err.Error())
+			continue
+		}
+		due, err := t.DueAt(loc, s.dayTime)
+		if err != nil {
+			log.Printf("Reminders of the task %s are skipped: %s\n", t.Id, err.Error())
+			continue
+		}
+		for _, remindAt := range s.remindersDue(t, due, now) {
+			r := Reminder{
+				TaskId:   t.Id,
+				Title:    t.Title,
+				Comment:  t.Comment,
+				Date:     t.Date,
+				Time:     t.Time,
+				TimeZone: loc.String(),
+				Login:    u.Login,
+				DueAt:    due.Format(time.RFC3339),
+				RemindAt: remindAt.Format(time.RFC3339),
+			}
+			for _, n := range s.notifiers {
+				delivered, err := s.deliver(ctx, n, r, remindAt, now)
+				if err != nil {
+					return sent, err
+				}
+				if delivered {
+					sent++
+				}
+			}
+		}
+	}
+	return sent, nil
+}
+
+// remindersDue returns the moments of the reminders of the task which have come by now,
+// the reminders aren't sent after the day of the task
+func (s *Scheduler) remindersDue(t task.Task, due time.Time, now time.Time) []time.Time {
+	offsets := s.defaults
+	if len(t.Reminders) > 0 {
+		//the reminders of the task are validated when it's saved
+		offsets, _ = task.ParseReminders(t.Reminders)
+	}
+	dayEnd := time.Date(due.Year(), due.Month(), due.Day()+1, 0, 0, 0, 0, due.Location())
+	if !now.Before(dayEnd) {
+		return nil
+	}
+	moments := []time.Time{}
+	for _, offset := range offsets {
+		remindAt := offset.RemindAt(due)
+		if !remindAt.After(now) {
+			moments = append(moments, remindAt)
+		}
+	}
+	return moments
+}
+
+// deliver sends the reminder through the notifier if it hasn't been sent yet and saves the delivery state.
+// The failed delivery is retried by the next runs up to maxAttempts times.
+func (s *Scheduler) deliver(ctx context.Context, n Notifier, r Reminder, remindAt time.Time, now time.Time) (bool, error) {
+	taskId, err := strconv.Atoi(r.TaskId)
+	if err != nil {
+		return false, err
+	}
+	key := remindAt.UTC().Format(time.RFC3339)
+	d, err := s.repo.GetDelivery(taskId, key, n.Channel())
+	if errors.Is(err, sql.ErrNoRows) {
+		d = &storage.Delivery{TaskId: taskId, RemindAt: key, Channel: n.Channel()}
+	} else if err != nil {
+		return false, err
+	}
+	if len(d.SentAt) > 0 || d.Attempts >= maxAttempts {
+		return false, nil
+	}
+
+	notifyCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	notifyErr := n.Notify(notifyCtx, r)
+	d.Attempts++
+	if notifyErr != nil {
+		d.Error = notifyErr.Error()
+		log.Printf("Error during sending of the reminder of the task %s by %s, attempt %d: %s\n",
+			r.TaskId, n.Channel(), d.Attempts, d.Error)
+	} else {
+		d.SentAt = now.UTC().Format(time.RFC3339)
+		d.Error = ""
+	}
+	if err := s.repo.SaveDelivery(d); err != nil {
+		return false, err
+	}
+	return notifyErr == nil, nil
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"github.com/OlegShamkeev/go_final_project/internal/apikey"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const apiKeyColumns = `id, user_id, name, hint, key_hash, scope, role, created_at, expires_at`
+
+// ApiKeyRepository keeps API keys of the user. GetApiKeyByHash isn't scoped by ForUser,
+// it's used to authenticate the request.
+type ApiKeyRepository interface {
+	CreateApiKey(key *apikey.Key) (int, error)
+	GetApiKeys() ([]apikey.Key, error)
+	DeleteApiKey(id int) error
+	GetApiKeyByHash(hash string) (*apikey.Key, error)
+}
+
+func (t sqlStorage) CreateApiKey(key *apikey.Key) (int, error) {
+	insertRow := `INSERT INTO api_keys (user_id, name, hint, key_hash, scope, role, created_at, expires_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`
+	var id int
+	err := sqlx.Get(t.conn(), &id, t.Db.Rebind(insertRow),
+		t.userId, key.Name, key.Hint, key.Hash, key.Scope, key.Role, key.CreatedAt, key.ExpiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (t sqlStorage) GetApiKeys() ([]apikey.Key, error) {
+	keys := []apikey.Key{}
+	selectRows := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = ? ORDER BY id`
+	if err := sqlx.Select(t.conn(), &keys, t.Db.Rebind(selectRows), t.userId); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (t sqlStorage) DeleteApiKey(id int) error {
+	deleteRow := `DELETE FROM api_keys WHERE id = ? AND user_id = ?`
+	res, err := t.conn().Exec(t.Db.Rebind(deleteRow), id, t.userId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (t sqlStorage) GetApiKeyByHash(hash string) (*apikey.Key, error) {
+	key := &apikey.Key{}
+	selectRow := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = ?`
+	if err := sqlx.Get(t.conn(), key, t.Db.Rebind(selectRow), hash); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
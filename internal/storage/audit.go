@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"github.com/jmoiron/sqlx"
+)
+
+// AuditEntry records the attempt to change the task or to sign in. CreatedAt is in the time.RFC3339 format
+// of UTC, Status is the status of the response and Diff is the JSON of the changed fields of the task.
+type AuditEntry struct {
+	Id         int       `json:"id" db:"id"`
+	CreatedAt  string    `json:"created_at" db:"created_at"`
+	ActorId    int       `json:"actor_id,omitempty" db:"actor_id"`
+	Actor      string    `json:"actor" db:"actor"`
+	RemoteAddr string    `json:"remote_addr" db:"remote_addr"`
+	Endpoint   string    `json:"endpoint" db:"endpoint"`
+	Action     string    `json:"action" db:"action"`
+	TaskId     int       `json:"task_id,omitempty" db:"task_id"`
+	Status     int       `json:"status" db:"status"`
+	Error      string    `json:"error,omitempty" db:"error"`
+	Diff       AuditDiff `json:"diff,omitempty" db:"diff"`
+}
+
+// AuditDiff is the JSON kept as the text, it's returned as is
+type AuditDiff string
+
+func (d AuditDiff) MarshalJSON() ([]byte, error) {
+	return []byte(d), nil
+}
+
+// AuditQuery filters the audit log, the zero fields aren't applied. Period from-to includes from and
+// excludes to, both are timestamps in the time.RFC3339 format of UTC. The entries are returned from
+// the latest one, Before is the id the previous page ended with.
+type AuditQuery struct {
+	TaskId int
+	Actor  string
+	From   string
+	To     string
+	Before int
+	Limit  int
+}
+
+// AuditRepository keeps the audit log, it isn't scoped by ForUser. The entries should be added outside
+// of the transactions, so the attempts rolled back are kept as well.
+type AuditRepository interface {
+	AddAuditEntry(e *AuditEntry) error
+	GetAuditLog(query AuditQuery) ([]AuditEntry, error)
+}
+
+const auditColumns = `id, created_at, actor_id, actor, remote_addr, endpoint, action, task_id, status, error, diff`
+
+func (t sqlStorage) AddAuditEntry(e *AuditEntry) error {
+	insertRow := `INSERT INTO audit_log (created_at, actor_id, actor, remote_addr, endpoint, action, task_id, status, error, diff)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`
+	return sqlx.Get(t.conn(), &e.Id, t.Db.Rebind(insertRow),
+		e.CreatedAt, e.ActorId, e.Actor, e.RemoteAddr, e.Endpoint, e.Action, e.TaskId, e.Status, e.Error, string(e.Diff))
+}
+
+func (t sqlStorage) GetAuditLog(query AuditQuery) ([]AuditEntry, error) {
+	entries := []AuditEntry{}
+	selectRows := `SELECT ` + auditColumns + ` FROM audit_log WHERE 1 = 1`
+	args := []any{}
+	if query.TaskId > 0 {
+		selectRows += ` AND task_id = ?`
+		args = append(args, query.TaskId)
+	}
+	if len(query.Actor) > 0 {
+		selectRows += ` AND actor = ?`
+		args = append(args, query.Actor)
+	}
+	if len(query.From) > 0 {
+		selectRows += ` AND created_at >= ?`
+		args = append(args, query.From)
+	}
+	if len(query.To) > 0 {
+		selectRows += ` AND created_at < ?`
+		args = append(args, query.To)
+	}
+	if query.Before > 0 {
+		selectRows += ` AND id < ?`
+		args = append(args, query.Before)
+	}
+	selectRows += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, query.Limit)
+	if err := sqlx.Select(t.conn(), &entries, t.Db.Rebind(selectRows), args...); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
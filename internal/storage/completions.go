@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const completionColumns = `id, task_id, title, date, completed_at, user_id, project_id`
+
+// CompletionRepository keeps history of the done tasks. Period from-to includes from and excludes to,
+// both are timestamps in the time.RFC3339 format of UTC, empty bound isn't applied.
+type CompletionRepository interface {
+	AddCompletion(completion *task.Completion) (int, error)
+	GetTaskCompletions(taskId int) ([]task.Completion, error)
+	GetCompletions(from string, to string) ([]task.Completion, error)
+}
+
+func (t sqlStorage) AddCompletion(completion *task.Completion) (int, error) {
+	insertRow := `INSERT INTO completions (task_id, title, date, completed_at, user_id, project_id)
+	VALUES (?, ?, ?, ?, ?, ?) RETURNING id`
+	var id int
+	err := sqlx.Get(t.conn(), &id, t.Db.Rebind(insertRow),
+		completion.TaskId, completion.Title, completion.Date, completion.CompletedAt, t.userId, completion.ProjectId)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (t sqlStorage) GetTaskCompletions(taskId int) ([]task.Completion, error) {
+	completions := []task.Completion{}
+	selectRows := `SELECT ` + completionColumns + ` FROM completions WHERE ` + memberProjects + ` AND task_id = ?
+	ORDER BY completed_at DESC, id DESC`
+	if err := sqlx.Select(t.conn(), &completions, t.Db.Rebind(selectRows), t.userId, taskId); err != nil {
+		return nil, err
+	}
+	return completions, nil
+}
+
+func (t sqlStorage) GetCompletions(from string, to string) ([]task.Completion, error) {
+	completions := []task.Completion{}
+	selectRows := `SELECT ` + completionColumns + ` FROM completions WHERE ` + memberProjects
+	args := []any{t.userId}
+	if len(from) > 0 {
+		selectRows += ` AND completed_at >= ?`
+		args = append(args, from)
+	}
+	if len(to) > 0 {
+		selectRows += ` AND completed_at < ?`
+		args = append(args, to)
+	}
+	selectRows += ` ORDER BY completed_at DESC, id DESC`
+	if err := sqlx.Select(t.conn(), &completions, t.Db.Rebind(selectRows), args...); err != nil {
+		return nil, err
+	}
+	return completions, nil
+}
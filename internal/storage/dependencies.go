@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrDependencyCycle is returned for the dependency which would make the task wait for itself
+var ErrDependencyCycle = errors.New("dependency would make a cycle")
+
+// openBlockers selects the open blockers of the task selected by the outer query on the scheduler table,
+// the repeating blocker stays open until it moves past the date of the task
+const openBlockers = `SELECT b.id FROM task_dependencies d JOIN scheduler b ON b.id = d.blocker_id
+	WHERE d.task_id = scheduler.id AND b.deleted_at = '' AND (b.repeat = '' OR b.date <= scheduler.date)`
+
+// DependencyRepository keeps the dependencies between the tasks. AddDependency and DeleteDependency
+// aren't scoped by ForUser, the caller checks the roles of the user in the projects of both tasks.
+type DependencyRepository interface {
+	// GetDependencies returns the dependencies of the task on its blockers and of the other tasks on it
+	GetDependencies(taskId int) ([]task.Dependency, error)
+	// AddDependency returns ErrDependencyCycle if the blocker depends on the task, the existing dependency is kept
+	AddDependency(d task.Dependency) error
+	DeleteDependency(d task.Dependency) error
+	// GetOpenBlockers returns ids of the open blockers of the task
+	GetOpenBlockers(taskId int) ([]int, error)
+}
+
+func (t sqlStorage) GetDependencies(taskId int) ([]task.Dependency, error) {
+	dependencies := []task.Dependency{}
+	selectRows := `SELECT task_id, blocker_id FROM task_dependencies WHERE task_id = ? OR blocker_id = ?
+	ORDER BY task_id, blocker_id`
+	if err := sqlx.Select(t.conn(), &dependencies, t.Db.Rebind(selectRows), taskId, taskId); err != nil {
+		return nil, err
+	}
+	return dependencies, nil
+}
+
+func (t sqlStorage) AddDependency(d task.Dependency) error {
+	if d.TaskId == d.BlockerId {
+		return ErrDependencyCycle
+	}
+	return t.withTx(func(bound sqlStorage) error {
+		//the task is reachable from the blocker if the blocker already waits for it
+		var found int
+		selectChain := `WITH RECURSIVE chain (id) AS (
+			SELECT CAST(? AS INTEGER)
+			UNION SELECT d.blocker_id FROM task_dependencies d JOIN chain c ON d.task_id = c.id
+		) SELECT COUNT(*) FROM chain WHERE id = ?`
+		if err := sqlx.Get(bound.conn(), &found, t.Db.Rebind(selectChain), d.BlockerId, d.TaskId); err != nil {
+			return err
+		}
+		if found > 0 {
+			return ErrDependencyCycle
+		}
+		insertRow := `INSERT INTO task_dependencies (task_id, blocker_id) VALUES (?, ?)
+		ON CONFLICT (task_id, blocker_id) DO NOTHING`
+		_, err := bound.conn().Exec(t.Db.Rebind(insertRow), d.TaskId, d.BlockerId)
+		return err
+	})
+}
+
+func (t sqlStorage) DeleteDependency(d task.Dependency) error {
+	deleteRow := `DELETE FROM task_dependencies WHERE task_id = ? AND blocker_id = ?`
+	res, err := t.conn().Exec(t.Db.Rebind(deleteRow), d.TaskId, d.BlockerId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (t sqlStorage) GetOpenBlockers(taskId int) ([]int, error) {
+	ids := []int{}
+	selectRows := `SELECT id FROM scheduler WHERE id IN (SELECT blocker_id FROM task_dependencies WHERE task_id = ?)
+	AND deleted_at = '' AND (repeat = '' OR date <= (SELECT date FROM scheduler WHERE id = ? AND ` + memberProjects + `))
+	ORDER BY id`
+	if err := sqlx.Select(t.conn(), &ids, t.Db.Rebind(selectRows), taskId, taskId, t.userId); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
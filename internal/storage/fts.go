@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ftsActive checks if the full text index is created by the migration
+const ftsActive = `SELECT COUNT(*) = 1 FROM sqlite_master WHERE type = 'table' AND name = 'scheduler_fts'`
+
+// ftsAvailable checks if the index is created and the build is able to use it
+const ftsAvailable = `SELECT sqlite_compileoption_used('ENABLE_FTS5') AND (` + ftsActive + `)`
+
+// snippet markers are replaced by the html tags after the snippet is escaped
+const (
+	snippetStart = "\x02"
+	snippetEnd   = "\x03"
+	// snippetTokens is the size of the snippet
+	snippetTokens = 12
+)
+
+// searchResult is the task found by the full text search with its relevance, the less rank is the better
+type searchResult struct {
+	task.Task
+	Rank float64 `db:"rank"`
+}
+
+// searchTasks finds the tasks by the full text index ordered by relevance or by the sort key of the query
+func (t sqlStorage) searchTasks(query TaskQuery, text string) (*TaskPage, error) {
+	match := ftsQuery(text)
+	page := &TaskPage{Tasks: []task.Task{}}
+	if len(match) == 0 {
+		return page, nil
+	}
+
+	//filters refer to the columns of the scheduler table only, which are unique in the join
+	filters, filterArgs := query.filters()
+	where := `scheduler_fts MATCH ? AND ` + strings.Join(filters, " AND ")
+	filterArgs = append([]any{match}, filterArgs...)
+
+	countRows := `SELECT COUNT(*) FROM scheduler_fts JOIN scheduler ON scheduler.id = scheduler_fts.rowid WHERE ` + where
+	if err := sqlx.Get(t.conn(), &page.Total, countRows, filterArgs...); err != nil {
+		return nil, err
+	}
+
+	sorted := len(query.Sort) > 0
+	orderBy := `rank, id`
+	conds := []string{"1 = 1"}
+	args := append([]any{snippetStart, snippetEnd, snippetTokens}, filterArgs...)
+	if sorted {
+		orderBy = query.orderBy()
+	}
+	if len(query.Cursor) > 0 {
+		c, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if sorted {
+			if err := c.split(query); err != nil {
+				return nil, err
+			}
+			cond, condArgs := query.afterCursor(c)
+			conds = append(conds, cond)
+			args = append(args, condArgs...)
+		} else {
+			rank, err := strconv.ParseFloat(c.key, 64)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			conds = append(conds, `(rank > ? OR (rank = ? AND id > ?))`)
+			args = append(args, rank, rank, c.id)
+		}
+	}
+
+	limit := query.limit()
+	selectRows := `SELECT * FROM (
+		SELECT scheduler.id, scheduler.date, scheduler.title, scheduler.comment, scheduler.repeat, scheduler.deleted_at,
+			scheduler.user_id, scheduler.project_id, scheduler.priority,
+			scheduler.time_of_day, scheduler.timezone, scheduler.reminders, scheduler.version,
+			EXISTS (` + openBlockers + `) AS blocked, snippet(scheduler_fts, -1, ?, ?, '…', ?) AS snippet, scheduler_fts.rank AS rank
+		FROM scheduler_fts JOIN scheduler ON scheduler.id = scheduler_fts.rowid
+		WHERE ` + where + `
+	) WHERE ` + strings.Join(conds, " AND ") + ` ORDER BY ` + orderBy + ` LIMIT ?`
+	//one more task is selected to know if there is the next page
+	args = append(args, limit+1)
+	results := []searchResult{}
+	if err := sqlx.Select(t.conn(), &results, selectRows, args...); err != nil {
+		return nil, err
+	}
+
+	if len(results) > limit {
+		results = results[:limit]
+		last := results[limit-1]
+		page.NextCursor = encodeCursor(strconv.FormatFloat(last.Rank, 'g', -1, 64), last.Id)
+		if sorted {
+			page.NextCursor = encodeCursor(query.sortKey(last.Task), last.Id)
+		}
+	}
+	for _, r := range results {
+		r.Snippet = highlight(r.Snippet)
+		page.Tasks = append(page.Tasks, r.Task)
+	}
+	if err := t.loadTags(page.Tasks); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// indexTasks adds the selected tasks to the full text index, it's called after the tasks are inserted or changed
+func (t sqlStorage) indexTasks(cond string, args ...any) error {
+	if !t.fts {
+		return nil
+	}
+	insertRows := `INSERT INTO scheduler_fts (rowid, title, comment) SELECT id, title, comment FROM scheduler WHERE ` + cond
+	_, err := t.conn().Exec(t.Db.Rebind(insertRows), args...)
+	return err
+}
+
+// unindexTasks removes the selected tasks from the full text index, it's called before the tasks are changed
+// or deleted, the external content index needs the values it was built from
+func (t sqlStorage) unindexTasks(cond string, args ...any) error {
+	if !t.fts {
+		return nil
+	}
+	insertRows := `INSERT INTO scheduler_fts (scheduler_fts, rowid, title, comment)
+	SELECT 'delete', id, title, comment FROM scheduler WHERE ` + cond
+	_, err := t.conn().Exec(t.Db.Rebind(insertRows), args...)
+	return err
+}
+
+// highlight escapes the snippet and wraps the matched tokens by <mark>
+func highlight(snippet string) string {
+	snippet = html.EscapeString(snippet)
+	snippet = strings.ReplaceAll(snippet, snippetStart, "<mark>")
+	return strings.ReplaceAll(snippet, snippetEnd, "</mark>")
+}
+
+// ftsQuery converts the search text to the FTS5 query. Words and "quoted phrases" are matched as is,
+// word* or "phrase"* match by prefix, AND, OR and NOT between terms are boolean operators, adjacent terms are joined by AND.
+// All other characters are quoted, so the user input can't break the query syntax.
+func ftsQuery(text string) string {
+	type item struct {
+		value    string
+		operator bool
+	}
+	var items []item
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		switch {
+		case runes[i] == ' ' || runes[i] == '\t' || runes[i] == '\n':
+			i++
+		case runes[i] == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			phrase := string(runes[i+1 : min(end, len(runes))])
+			i = end + 1
+			prefix := i < len(runes) && runes[i] == '*'
+			if prefix {
+				i++
+			}
+			if len(strings.TrimSpace(phrase)) > 0 {
+				items = append(items, item{value: ftsString(phrase, prefix)})
+			}
+		default:
+			end := i
+			for end < len(runes) && runes[end] != ' ' && runes[end] != '\t' && runes[end] != '\n' && runes[end] != '"' {
+				end++
+			}
+			word := string(runes[i:end])
+			i = end
+			if word == "AND" || word == "OR" || word == "NOT" {
+				items = append(items, item{value: word, operator: true})
+				continue
+			}
+			prefix := strings.HasSuffix(word, "*")
+			word = strings.TrimRight(word, "*")
+			if len(word) > 0 {
+				items = append(items, item{value: ftsString(word, prefix)})
+			}
+		}
+	}
+
+	var parts []string
+	for i, it := range items {
+		if it.operator {
+			//operator which isn't between two terms is searched as a word
+			if len(parts) == 0 || items[i-1].operator || i == len(items)-1 || items[i+1].operator {
+				parts = append(parts, ftsString(strings.ToLower(it.value), false))
+				continue
+			}
+		}
+		parts = append(parts, it.value)
+	}
+	return strings.Join(parts, " ")
+}
+
+func ftsString(s string, prefix bool) string {
+	result := `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	if prefix {
+		result += "*"
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"strconv"
+
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// memberTasks limits the items to the tasks not in the trash of the projects the user is a member of
+const memberTasks = `task_id IN (SELECT id FROM scheduler WHERE ` + memberProjects + ` AND deleted_at = '')`
+
+// ItemRepository keeps the checklists of the tasks, the items are returned by TaskRepository.GetTask as well.
+// The items of the task out of reach of the user aren't found.
+type ItemRepository interface {
+	GetItems(taskId int) ([]task.Item, error)
+	AddItem(item *task.Item) (int, error)
+	UpdateItem(item *task.Item) error
+	DeleteItem(taskId int, id int) error
+	// ResetItems marks all items of the task as not done
+	ResetItems(taskId int) error
+}
+
+func (t sqlStorage) GetItems(taskId int) ([]task.Item, error) {
+	items := []task.Item{}
+	selectRows := `SELECT id, task_id, title, done FROM task_items WHERE task_id = ? AND ` + memberTasks + ` ORDER BY id`
+	if err := sqlx.Select(t.conn(), &items, t.Db.Rebind(selectRows), taskId, t.userId); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (t sqlStorage) AddItem(item *task.Item) (int, error) {
+	var id int
+	err := t.withTx(func(bound sqlStorage) error {
+		selectRow := `SELECT id FROM scheduler WHERE id = ? AND ` + memberProjects + ` AND deleted_at = ''`
+		if err := sqlx.Get(bound.conn(), &id, t.Db.Rebind(selectRow), item.TaskId, t.userId); err != nil {
+			return err
+		}
+		insertRow := `INSERT INTO task_items (task_id, title, done) VALUES (?, ?, ?) RETURNING id`
+		return sqlx.Get(bound.conn(), &id, t.Db.Rebind(insertRow), item.TaskId, item.Title, item.Done)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (t sqlStorage) UpdateItem(item *task.Item) error {
+	updateRow := `UPDATE task_items SET title = ?, done = ? WHERE id = ? AND task_id = ? AND ` + memberTasks
+	res, err := t.conn().Exec(t.Db.Rebind(updateRow), item.Title, item.Done, item.Id, item.TaskId, t.userId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (t sqlStorage) DeleteItem(taskId int, id int) error {
+	deleteRow := `DELETE FROM task_items WHERE id = ? AND task_id = ? AND ` + memberTasks
+	res, err := t.conn().Exec(t.Db.Rebind(deleteRow), id, taskId, t.userId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (t sqlStorage) ResetItems(taskId int) error {
+	updateRows := `UPDATE task_items SET done = ? WHERE task_id = ? AND ` + memberTasks
+	_, err := t.conn().Exec(t.Db.Rebind(updateRows), false, taskId, t.userId)
+	return err
+}
+
+// loadItems fills the checklist of the task
+func (t sqlStorage) loadItems(tk *task.Task) error {
+	id, err := strconv.Atoi(tk.Id)
+	if err != nil {
+		return err
+	}
+	items, err := t.GetItems(id)
+	if err != nil {
+		return err
+	}
+	if len(items) > 0 {
+		tk.Items = items
+	}
+	return nil
+}
@@ -0,0 +1,1132 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/apikey"
+	"github.com/OlegShamkeev/go_final_project/internal/project"
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+)
+
+// MemoryStorage keeps tasks in process memory, data is lost on restart. Intended for tests and demos.
+// Repositories returned by ForUser share the data with the one they are made from.
+type MemoryStorage struct {
+	*memoryData
+	userId int
+	inTx   bool
+}
+
+type memoryData struct {
+	mu     sync.RWMutex
+	tasks  map[int]task.Task
+	lastId int
+
+	completions      []task.Completion
+	lastCompletionId int
+
+	users      map[int]user.User
+	lastUserId int
+
+	// revokedTokens maps jti to the expiration time
+	revokedTokens map[string]string
+
+	apiKeys      map[int]apikey.Key
+	lastApiKeyId int
+
+	projects      map[int]project.Project
+	lastProjectId int
+	// members maps the project to the roles of its members
+	members map[int]map[int]string
+
+	items      map[int]task.Item
+	lastItemId int
+
+	dependencies map[task.Dependency]bool
+
+	// deliveries maps the task, the moment and the channel of the reminder to its delivery state
+	deliveries map[deliveryKey]Delivery
+
+	audit       []AuditEntry
+	lastAuditId int
+
+	// txMu serializes transactions and the changes made outside of them,
+	// so the commit of the copy doesn't overwrite the change made while it runs
+	txMu sync.Mutex
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	data := &memoryData{
+		tasks:         make(map[int]task.Task),
+		users:         make(map[int]user.User),
+		revokedTokens: make(map[string]string),
+		apiKeys:       make(map[int]apikey.Key),
+		projects:      make(map[int]project.Project),
+		members:       make(map[int]map[int]string),
+		items:         make(map[int]task.Item),
+		dependencies:  make(map[task.Dependency]bool),
+		deliveries:    make(map[deliveryKey]Delivery),
+	}
+	//the same default user with its default project as the ones created by migrations
+	data.users[user.DefaultId] = user.User{Id: user.DefaultId, Login: user.DefaultLogin, Role: user.RoleAdmin}
+	data.lastUserId = user.DefaultId
+	m := &MemoryStorage{memoryData: data, userId: user.DefaultId}
+	m.CreateProject(&project.Project{Name: project.DefaultName, IsDefault: true})
+	return &MemoryStorage{memoryData: data}
+}
+
+func (m *MemoryStorage) ForUser(userId int) Repository {
+	return &MemoryStorage{memoryData: m.memoryData, userId: userId, inTx: m.inTx}
+}
+
+func (m *MemoryStorage) Close() error {
+	return nil
+}
+
+// InTx runs fn against a copy of the storage, the copy replaces the data only if fn succeeds,
+// the changes made outside of the transaction wait till it ends.
+// The nested call keeps the copy of the data to restore it if fn fails, like the savepoint.
+func (m *MemoryStorage) InTx(fn func(repo Repository) error) error {
+	if m.inTx {
+		m.mu.RLock()
+		saved := m.clone()
+		m.mu.RUnlock()
+		if err := fn(m); err != nil {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.restore(saved)
+			return err
+		}
+		return nil
+	}
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	m.mu.RLock()
+	tx := &MemoryStorage{memoryData: m.clone(), userId: m.userId, inTx: true}
+	m.mu.RUnlock()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.restore(tx.memoryData)
+	return nil
+}
+
+// lock locks the data for a change, outside of a transaction it waits till the running transaction is committed
+func (m *MemoryStorage) lock() {
+	if !m.inTx {
+		m.txMu.Lock()
+	}
+	m.mu.Lock()
+}
+
+func (m *MemoryStorage) unlock() {
+	m.mu.Unlock()
+	if !m.inTx {
+		m.txMu.Unlock()
+	}
+}
+
+// clone copies the data of the storage, caller must hold the lock
+func (m *memoryData) clone() *memoryData {
+	c := &memoryData{tasks: make(map[int]task.Task, len(m.tasks)), lastId: m.lastId}
+	for id, t := range m.tasks {
+		c.tasks[id] = t
+	}
+	c.completions = append([]task.Completion{}, m.completions...)
+	c.lastCompletionId = m.lastCompletionId
+	c.users = make(map[int]user.User, len(m.users))
+	for id, u := range m.users {
+		c.users[id] = u
+	}
+	c.lastUserId = m.lastUserId
+	c.revokedTokens = make(map[string]string, len(m.revokedTokens))
+	for jti, expiresAt := range m.revokedTokens {
+		c.revokedTokens[jti] = expiresAt
+	}
+	c.apiKeys = make(map[int]apikey.Key, len(m.apiKeys))
+	for id, k := range m.apiKeys {
+		c.apiKeys[id] = k
+	}
+	c.lastApiKeyId = m.lastApiKeyId
+	c.projects = make(map[int]project.Project, len(m.projects))
+	for id, p := range m.projects {
+		c.projects[id] = p
+	}
+	c.lastProjectId = m.lastProjectId
+	c.members = make(map[int]map[int]string, len(m.members))
+	for id, roles := range m.members {
+		c.members[id] = make(map[int]string, len(roles))
+		for userId, role := range roles {
+			c.members[id][userId] = role
+		}
+	}
+	c.items = make(map[int]task.Item, len(m.items))
+	for id, item := range m.items {
+		c.items[id] = item
+	}
+	c.lastItemId = m.lastItemId
+	c.dependencies = make(map[task.Dependency]bool, len(m.dependencies))
+	for d := range m.dependencies {
+		c.dependencies[d] = true
+	}
+	c.deliveries = make(map[deliveryKey]Delivery, len(m.deliveries))
+	for key, d := range m.deliveries {
+		c.deliveries[key] = d
+	}
+	c.audit, c.lastAuditId = append([]AuditEntry{}, m.audit...), m.lastAuditId
+	return c
+}
+
+// restore replaces the data of the storage by the data of the clone, caller must hold the lock
+func (m *memoryData) restore(c *memoryData) {
+	m.tasks, m.lastId = c.tasks, c.lastId
+	m.completions, m.lastCompletionId = c.completions, c.lastCompletionId
+	m.users, m.lastUserId = c.users, c.lastUserId
+	m.revokedTokens = c.revokedTokens
+	m.apiKeys, m.lastApiKeyId = c.apiKeys, c.lastApiKeyId
+	m.projects, m.lastProjectId, m.members = c.projects, c.lastProjectId, c.members
+	m.items, m.lastItemId = c.items, c.lastItemId
+	m.dependencies = c.dependencies
+	m.deliveries = c.deliveries
+	m.audit, m.lastAuditId = c.audit, c.lastAuditId
+}
+
+// owned returns the task if the user of the storage is a member of its project, caller must hold the lock
+func (m *MemoryStorage) owned(id int) (task.Task, bool) {
+	t, ok := m.tasks[id]
+	return t, ok && m.isMember(t.ProjectId)
+}
+
+// isMember checks the membership of the user of the storage in the project, caller must hold the lock
+func (m *MemoryStorage) isMember(projectId string) bool {
+	id, _ := strconv.Atoi(projectId)
+	_, ok := m.members[id][m.userId]
+	return ok
+}
+
+// defaultProject returns the default project of the user of the storage, caller must hold the lock
+func (m *MemoryStorage) defaultProject() (*project.Project, error) {
+	for _, p := range m.projects {
+		if p.OwnerId == m.userId && p.IsDefault {
+			p.Role = m.members[p.Id][m.userId]
+			return &p, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStorage) CreateTask(task *task.Task) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	t := *task
+	if len(t.ProjectId) == 0 {
+		p, err := m.defaultProject()
+		if err != nil {
+			return 0, err
+		}
+		t.ProjectId = strconv.Itoa(p.Id)
+	}
+	m.lastId++
+	t.Id = strconv.Itoa(m.lastId)
+	t.UserId = m.userId
+	t.Version = 1
+	t.Priority = priorityOrDefault(&t)
+	t.Tags = sortedTags(t.Tags)
+	m.tasks[m.lastId] = t
+
+	return m.lastId, nil
+}
+
+func (m *MemoryStorage) GetTasks(query TaskQuery) (*TaskPage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	query.userId = m.userId
+	var c *cursor
+	if len(query.Cursor) > 0 {
+		var err error
+		if c, err = decodeCursor(query.Cursor); err == nil {
+			err = c.split(query)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	page := &TaskPage{Tasks: []task.Task{}}
+	for _, t := range m.tasks {
+		if !m.isMember(t.ProjectId) || !query.match(t) {
+			continue
+		}
+		page.Total++
+		if c == nil || c.after(query, t) {
+			page.Tasks = append(page.Tasks, t)
+		}
+	}
+	sort.Slice(page.Tasks, func(i, j int) bool {
+		return query.less(page.Tasks[i], page.Tasks[j])
+	})
+
+	if limit := query.limit(); len(page.Tasks) > limit {
+		page.Tasks = page.Tasks[:limit]
+		last := page.Tasks[limit-1]
+		page.NextCursor = encodeCursor(query.sortKey(last), last.Id)
+	}
+	m.withBlocked(page.Tasks)
+	return page, nil
+}
+
+func (m *MemoryStorage) GetAllTasks() ([]task.Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tasks := make([]task.Task, 0, len(m.tasks))
+	for _, t := range m.tasks {
+		if m.isMember(t.ProjectId) && len(t.DeletedAt) == 0 {
+			tasks = append(tasks, t)
+		}
+	}
+	sortTasks(tasks)
+	m.withBlocked(tasks)
+	return tasks, nil
+}
+
+func (m *MemoryStorage) GetTask(id int) (*task.Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, ok := m.owned(id)
+	if !ok || len(t.DeletedAt) > 0 {
+		return nil, sql.ErrNoRows
+	}
+	t.Blocked = len(m.openBlockers(t)) > 0
+	//the checklist is kept apart from the task
+	t.Items = nil
+	if items := m.taskItems(id); len(items) > 0 {
+		t.Items = items
+	}
+	return &t, nil
+}
+
+func (m *MemoryStorage) UpdateTask(task *task.Task) error {
+	m.lock()
+	defer m.unlock()
+
+	id, err := strconv.Atoi(task.Id)
+	if err != nil {
+		return err
+	}
+	t, ok := m.owned(id)
+	ok = ok && len(t.DeletedAt) == 0
+	if task.Version > 0 && (!ok || t.Version != task.Version) {
+		return ErrVersionConflict
+	}
+	if ok {
+		updated := *task
+		updated.UserId = t.UserId
+		updated.Version = t.Version + 1
+		if len(updated.ProjectId) == 0 {
+			updated.ProjectId = t.ProjectId
+		}
+		if len(updated.Priority) == 0 {
+			updated.Priority = t.Priority
+		}
+		updated.Tags = t.Tags
+		if task.Tags != nil {
+			updated.Tags = sortedTags(task.Tags)
+		}
+		m.tasks[id] = updated
+	}
+	return nil
+}
+
+func (m *MemoryStorage) DeleteTask(id int) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.owned(id); ok {
+		m.deleteTask(id)
+	}
+	return nil
+}
+
+func (m *MemoryStorage) TrashTask(id int, deletedAt string) error {
+	m.lock()
+	defer m.unlock()
+
+	t, ok := m.owned(id)
+	if !ok || len(t.DeletedAt) > 0 {
+		return sql.ErrNoRows
+	}
+	t.DeletedAt = deletedAt
+	m.tasks[id] = t
+	return nil
+}
+
+func (m *MemoryStorage) RestoreTask(id int) error {
+	m.lock()
+	defer m.unlock()
+
+	t, ok := m.owned(id)
+	if !ok || len(t.DeletedAt) == 0 {
+		return sql.ErrNoRows
+	}
+	t.DeletedAt = ""
+	m.tasks[id] = t
+	return nil
+}
+
+func (m *MemoryStorage) GetTrash() ([]task.Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tasks := []task.Task{}
+	for _, t := range m.tasks {
+		if m.isMember(t.ProjectId) && len(t.DeletedAt) > 0 {
+			tasks = append(tasks, t)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].DeletedAt != tasks[j].DeletedAt {
+			return tasks[i].DeletedAt > tasks[j].DeletedAt
+		}
+		idI, _ := strconv.Atoi(tasks[i].Id)
+		idJ, _ := strconv.Atoi(tasks[j].Id)
+		return idI > idJ
+	})
+	m.withBlocked(tasks)
+	return tasks, nil
+}
+
+func (m *MemoryStorage) PurgeTrash(before string) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	purged := 0
+	for id, t := range m.tasks {
+		if len(t.DeletedAt) > 0 && t.DeletedAt < before {
+			m.deleteTask(id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// deleteTask deletes the task with its checklist and dependencies, caller must hold the lock
+func (m *MemoryStorage) deleteTask(id int) {
+	delete(m.tasks, id)
+	for itemId, item := range m.items {
+		if item.TaskId == id {
+			delete(m.items, itemId)
+		}
+	}
+	for d := range m.dependencies {
+		if d.TaskId == id || d.BlockerId == id {
+			delete(m.dependencies, d)
+		}
+	}
+}
+
+// sortedTags copies the tags in the order they are returned by SQL backends
+func sortedTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	return tags
+}
+
+func (m *MemoryStorage) GetTags() ([]TagCount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, t := range m.tasks {
+		if m.isMember(t.ProjectId) && len(t.DeletedAt) == 0 {
+			for _, tag := range t.Tags {
+				counts[tag]++
+			}
+		}
+	}
+	tags := make([]TagCount, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, TagCount{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+func (m *MemoryStorage) AddCompletion(completion *task.Completion) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	m.lastCompletionId++
+	c := *completion
+	c.Id = strconv.Itoa(m.lastCompletionId)
+	c.UserId = m.userId
+	m.completions = append(m.completions, c)
+
+	return m.lastCompletionId, nil
+}
+
+func (m *MemoryStorage) GetTaskCompletions(taskId int) ([]task.Completion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id := strconv.Itoa(taskId)
+	completions := []task.Completion{}
+	for i := len(m.completions) - 1; i >= 0; i-- {
+		if m.isMember(m.completions[i].ProjectId) && m.completions[i].TaskId == id {
+			completions = append(completions, m.completions[i])
+		}
+	}
+	sortCompletions(completions)
+	return completions, nil
+}
+
+func (m *MemoryStorage) GetCompletions(from string, to string) ([]task.Completion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	completions := []task.Completion{}
+	for i := len(m.completions) - 1; i >= 0; i-- {
+		c := m.completions[i]
+		if !m.isMember(c.ProjectId) {
+			continue
+		}
+		if (len(from) == 0 || c.CompletedAt >= from) && (len(to) == 0 || c.CompletedAt < to) {
+			completions = append(completions, c)
+		}
+	}
+	sortCompletions(completions)
+	return completions, nil
+}
+
+// sortCompletions orders completions from the latest one, completions are appended in the order of ids
+func sortCompletions(completions []task.Completion) {
+	sort.SliceStable(completions, func(i, j int) bool {
+		return completions[i].CompletedAt > completions[j].CompletedAt
+	})
+}
+
+// sortTasks orders tasks by date and time the same way SQL backends do, ties are broken by id.
+func sortTasks(tasks []task.Task) {
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].Date+tasks[i].Time != tasks[j].Date+tasks[j].Time {
+			return tasks[i].Date+tasks[i].Time < tasks[j].Date+tasks[j].Time
+		}
+		idI, _ := strconv.Atoi(tasks[i].Id)
+		idJ, _ := strconv.Atoi(tasks[j].Id)
+		return idI < idJ
+	})
+}
+
+func (m *MemoryStorage) CreateUser(u *user.User) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	for _, existing := range m.users {
+		if existing.Login == u.Login {
+			return 0, fmt.Errorf("user %s already exists", u.Login)
+		}
+	}
+	m.lastUserId++
+	created := *u
+	created.Id = m.lastUserId
+	created.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	m.users[m.lastUserId] = created
+	return m.lastUserId, nil
+}
+
+func (m *MemoryStorage) GetUser(id int) (*user.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &u, nil
+}
+
+func (m *MemoryStorage) GetUserByLogin(login string) (*user.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, u := range m.users {
+		if u.Login == login {
+			return &u, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStorage) UpdateUserPassword(id int, passwordHash string) error {
+	m.lock()
+	defer m.unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	u.PasswordHash = passwordHash
+	m.users[id] = u
+	return nil
+}
+
+func (m *MemoryStorage) GetUsers() ([]user.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	users := make([]user.User, 0, len(m.users))
+	for _, u := range m.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Id < users[j].Id
+	})
+	return users, nil
+}
+
+func (m *MemoryStorage) UpdateUserRole(id int, role string) error {
+	m.lock()
+	defer m.unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	u.Role = role
+	m.users[id] = u
+	return nil
+}
+
+func (m *MemoryStorage) UpdateUserTimeZone(id int, timeZone string) error {
+	m.lock()
+	defer m.unlock()
+
+	u, ok := m.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	u.TimeZone = timeZone
+	m.users[id] = u
+	return nil
+}
+
+func (m *MemoryStorage) RevokeToken(jti string, userId int, expiresAt string) (bool, error) {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.revokedTokens[jti]; ok {
+		return false, nil
+	}
+	m.revokedTokens[jti] = expiresAt
+	return true, nil
+}
+
+func (m *MemoryStorage) IsTokenRevoked(jti string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.revokedTokens[jti]
+	return ok, nil
+}
+
+func (m *MemoryStorage) PurgeRevokedTokens(before string) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	purged := 0
+	for jti, expiresAt := range m.revokedTokens {
+		if expiresAt < before {
+			delete(m.revokedTokens, jti)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (m *MemoryStorage) CreateApiKey(key *apikey.Key) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	for _, existing := range m.apiKeys {
+		if existing.Hash == key.Hash {
+			return 0, fmt.Errorf("api key already exists")
+		}
+	}
+	m.lastApiKeyId++
+	created := *key
+	created.Id = m.lastApiKeyId
+	created.UserId = m.userId
+	m.apiKeys[m.lastApiKeyId] = created
+	return m.lastApiKeyId, nil
+}
+
+func (m *MemoryStorage) GetApiKeys() ([]apikey.Key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := []apikey.Key{}
+	for _, k := range m.apiKeys {
+		if k.UserId == m.userId {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Id < keys[j].Id
+	})
+	return keys, nil
+}
+
+func (m *MemoryStorage) DeleteApiKey(id int) error {
+	m.lock()
+	defer m.unlock()
+
+	k, ok := m.apiKeys[id]
+	if !ok || k.UserId != m.userId {
+		return sql.ErrNoRows
+	}
+	delete(m.apiKeys, id)
+	return nil
+}
+
+func (m *MemoryStorage) GetApiKeyByHash(hash string) (*apikey.Key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, k := range m.apiKeys {
+		if k.Hash == hash {
+			return &k, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryStorage) CreateProject(p *project.Project) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	m.lastProjectId++
+	created := *p
+	created.Id = m.lastProjectId
+	created.OwnerId = m.userId
+	created.Role = ""
+	created.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	m.projects[m.lastProjectId] = created
+	m.members[m.lastProjectId] = map[int]string{m.userId: user.RoleAdmin}
+	return m.lastProjectId, nil
+}
+
+// project returns the project with the role of the user if the user is its member, caller must hold the lock
+func (m *MemoryStorage) project(id int) (*project.Project, bool) {
+	p, ok := m.projects[id]
+	if !ok {
+		return nil, false
+	}
+	p.Role, ok = m.members[id][m.userId]
+	return &p, ok
+}
+
+func (m *MemoryStorage) GetProjects() ([]project.Project, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	projects := []project.Project{}
+	for id := range m.projects {
+		if p, ok := m.project(id); ok {
+			projects = append(projects, *p)
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].Id < projects[j].Id
+	})
+	return projects, nil
+}
+
+func (m *MemoryStorage) GetProject(id int) (*project.Project, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	p, ok := m.project(id)
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return p, nil
+}
+
+func (m *MemoryStorage) GetDefaultProject() (*project.Project, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.defaultProject()
+}
+
+func (m *MemoryStorage) UpdateProject(p *project.Project) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.project(p.Id); !ok {
+		return sql.ErrNoRows
+	}
+	updated := m.projects[p.Id]
+	updated.Name = p.Name
+	m.projects[p.Id] = updated
+	return nil
+}
+
+func (m *MemoryStorage) DeleteProject(id int) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.project(id); !ok {
+		return sql.ErrNoRows
+	}
+	projectId := strconv.Itoa(id)
+	for taskId, t := range m.tasks {
+		if t.ProjectId == projectId {
+			m.deleteTask(taskId)
+		}
+	}
+	completions := m.completions[:0]
+	for _, c := range m.completions {
+		if c.ProjectId != projectId {
+			completions = append(completions, c)
+		}
+	}
+	m.completions = completions
+	delete(m.members, id)
+	delete(m.projects, id)
+	return nil
+}
+
+func (m *MemoryStorage) GetMembers(projectId int) ([]project.Member, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	members := []project.Member{}
+	if _, ok := m.project(projectId); !ok {
+		return members, nil
+	}
+	for userId, role := range m.members[projectId] {
+		members = append(members, project.Member{
+			ProjectId: projectId, UserId: userId, Login: m.users[userId].Login, Role: role,
+		})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].UserId < members[j].UserId
+	})
+	return members, nil
+}
+
+func (m *MemoryStorage) SetMember(projectId int, userId int, role string) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.members[projectId]; !ok {
+		return fmt.Errorf("project %d doesn't exist", projectId)
+	}
+	m.members[projectId][userId] = role
+	return nil
+}
+
+func (m *MemoryStorage) DeleteMember(projectId int, userId int) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.members[projectId][userId]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.members[projectId], userId)
+	return nil
+}
+
+func (m *MemoryStorage) GetTaskRole(taskId int) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, ok := m.tasks[taskId]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	projectId, _ := strconv.Atoi(t.ProjectId)
+	role, ok := m.members[projectId][m.userId]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return role, nil
+}
+
+type deliveryKey struct {
+	taskId   int
+	remindAt string
+	channel  string
+}
+
+func (m *MemoryStorage) GetReminderTasks(from string, to string) ([]task.Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tasks := []task.Task{}
+	for _, t := range m.tasks {
+		if len(t.DeletedAt) == 0 && t.Date >= from && t.Date <= to {
+			tasks = append(tasks, t)
+		}
+	}
+	sortTasks(tasks)
+	return tasks, nil
+}
+
+func (m *MemoryStorage) GetDelivery(taskId int, remindAt string, channel string) (*Delivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	d, ok := m.deliveries[deliveryKey{taskId, remindAt, channel}]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return &d, nil
+}
+
+func (m *MemoryStorage) SaveDelivery(d *Delivery) error {
+	m.lock()
+	defer m.unlock()
+
+	m.deliveries[deliveryKey{d.TaskId, d.RemindAt, d.Channel}] = *d
+	return nil
+}
+
+func (m *MemoryStorage) PurgeDeliveries(before string) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	purged := 0
+	for key := range m.deliveries {
+		if key.remindAt < before {
+			delete(m.deliveries, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (m *MemoryStorage) AddAuditEntry(e *AuditEntry) error {
+	m.lock()
+	defer m.unlock()
+
+	m.lastAuditId++
+	e.Id = m.lastAuditId
+	m.audit = append(m.audit, *e)
+	return nil
+}
+
+func (m *MemoryStorage) GetAuditLog(query AuditQuery) ([]AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := []AuditEntry{}
+	for i := len(m.audit) - 1; i >= 0 && len(entries) < query.Limit; i-- {
+		e := m.audit[i]
+		if (query.TaskId > 0 && e.TaskId != query.TaskId) || (len(query.Actor) > 0 && e.Actor != query.Actor) ||
+			(len(query.From) > 0 && e.CreatedAt < query.From) || (len(query.To) > 0 && e.CreatedAt >= query.To) ||
+			(query.Before > 0 && e.Id >= query.Before) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// taskItems returns the checklist of the task ordered by id, caller must hold the lock
+func (m *MemoryStorage) taskItems(taskId int) []task.Item {
+	items := []task.Item{}
+	for _, item := range m.items {
+		if item.TaskId == taskId {
+			items = append(items, item)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Id < items[j].Id
+	})
+	return items
+}
+
+// ownedItem returns the item if its task is available to the user, caller must hold the lock
+func (m *MemoryStorage) ownedItem(taskId int, id int) (task.Item, bool) {
+	item, ok := m.items[id]
+	if !ok || item.TaskId != taskId {
+		return item, false
+	}
+	t, ok := m.owned(taskId)
+	return item, ok && len(t.DeletedAt) == 0
+}
+
+func (m *MemoryStorage) GetItems(taskId int) ([]task.Item, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if t, ok := m.owned(taskId); !ok || len(t.DeletedAt) > 0 {
+		return []task.Item{}, nil
+	}
+	return m.taskItems(taskId), nil
+}
+
+func (m *MemoryStorage) AddItem(item *task.Item) (int, error) {
+	m.lock()
+	defer m.unlock()
+
+	if t, ok := m.owned(item.TaskId); !ok || len(t.DeletedAt) > 0 {
+		return 0, sql.ErrNoRows
+	}
+	m.lastItemId++
+	added := *item
+	added.Id = m.lastItemId
+	m.items[added.Id] = added
+	return added.Id, nil
+}
+
+func (m *MemoryStorage) UpdateItem(item *task.Item) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.ownedItem(item.TaskId, item.Id); !ok {
+		return sql.ErrNoRows
+	}
+	m.items[item.Id] = *item
+	return nil
+}
+
+func (m *MemoryStorage) DeleteItem(taskId int, id int) error {
+	m.lock()
+	defer m.unlock()
+
+	if _, ok := m.ownedItem(taskId, id); !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.items, id)
+	return nil
+}
+
+func (m *MemoryStorage) ResetItems(taskId int) error {
+	m.lock()
+	defer m.unlock()
+
+	if t, ok := m.owned(taskId); !ok || len(t.DeletedAt) > 0 {
+		return nil
+	}
+	for id, item := range m.items {
+		if item.TaskId == taskId {
+			item.Done = false
+			m.items[id] = item
+		}
+	}
+	return nil
+}
+
+// openBlockers returns ids of the open blockers of the task in the ascending order, caller must hold the lock
+func (m *MemoryStorage) openBlockers(t task.Task) []int {
+	id, _ := strconv.Atoi(t.Id)
+	ids := []int{}
+	for d := range m.dependencies {
+		if d.TaskId != id {
+			continue
+		}
+		b, ok := m.tasks[d.BlockerId]
+		if ok && len(b.DeletedAt) == 0 && (len(b.Repeat) == 0 || b.Date <= t.Date) {
+			ids = append(ids, d.BlockerId)
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// withBlocked sets the blocked flag of the tasks, caller must hold the lock
+func (m *MemoryStorage) withBlocked(tasks []task.Task) {
+	for i := range tasks {
+		tasks[i].Blocked = len(m.openBlockers(tasks[i])) > 0
+	}
+}
+
+func (m *MemoryStorage) GetDependencies(taskId int) ([]task.Dependency, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	dependencies := []task.Dependency{}
+	for d := range m.dependencies {
+		if d.TaskId == taskId || d.BlockerId == taskId {
+			dependencies = append(dependencies, d)
+		}
+	}
+	sort.Slice(dependencies, func(i, j int) bool {
+		if dependencies[i].TaskId != dependencies[j].TaskId {
+			return dependencies[i].TaskId < dependencies[j].TaskId
+		}
+		return dependencies[i].BlockerId < dependencies[j].BlockerId
+	})
+	return dependencies, nil
+}
+
+func (m *MemoryStorage) AddDependency(d task.Dependency) error {
+	m.lock()
+	defer m.unlock()
+
+	//the task is reachable from the blocker if the blocker already waits for it
+	seen := map[int]bool{d.BlockerId: true}
+	queue := []int{d.BlockerId}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == d.TaskId {
+			return ErrDependencyCycle
+		}
+		for edge := range m.dependencies {
+			if edge.TaskId == id && !seen[edge.BlockerId] {
+				seen[edge.BlockerId] = true
+				queue = append(queue, edge.BlockerId)
+			}
+		}
+	}
+	m.dependencies[d] = true
+	return nil
+}
+
+func (m *MemoryStorage) DeleteDependency(d task.Dependency) error {
+	m.lock()
+	defer m.unlock()
+
+	if !m.dependencies[d] {
+		return sql.ErrNoRows
+	}
+	delete(m.dependencies, d)
+	return nil
+}
+
+func (m *MemoryStorage) GetOpenBlockers(taskId int) ([]int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	t, ok := m.owned(taskId)
+	if !ok {
+		return []int{}, nil
+	}
+	return m.openBlockers(t), nil
+}
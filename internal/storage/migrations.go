@@ -0,0 +1,616 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// sql.DB driver names used as keys of the migration scripts
+const (
+	sqlite3  = "sqlite3"
+	postgres = "postgres"
+)
+
+// script is the pair of the scripts applying and rolling back the migration. The migration depending on
+// the module of the database build is optional: up takes effect only if requires returns true, otherwise
+// fallback is run in place of up and down. Active returns if up has taken effect.
+type script struct {
+	up       string
+	down     string
+	requires string
+	active   string
+	fallback string
+}
+
+// migration holds up and down scripts written for SQLite, the dialect the first migrations were
+// released with. Dialects keep the scripts for the drivers which don't understand them.
+type migration struct {
+	version  int
+	name     string
+	up       string
+	down     string
+	requires string
+	active   string
+	fallback string
+	dialects map[string]script
+}
+
+// baselines replace the released migrations written before the driver was supported,
+// so the released migrations stay unchanged
+var baselines = map[string]map[int]script{
+	postgres: {
+		1: {
+			up: `CREATE TABLE IF NOT EXISTS scheduler (id SERIAL PRIMARY KEY, date CHAR(8) NOT NULL DEFAULT '',
+	title VARCHAR(256) NOT NULL DEFAULT '', comment TEXT NOT NULL DEFAULT '', repeat VARCHAR(128) NOT NULL DEFAULT '');
+	CREATE INDEX IF NOT EXISTS scheduler_date ON scheduler (date)`,
+			down: `DROP INDEX IF EXISTS scheduler_date;
+	DROP TABLE IF EXISTS scheduler`,
+		},
+	},
+}
+
+// scripts returns the scripts of the migration for the driver
+func (m migration) scripts(driver string) script {
+	if s, ok := baselines[driver][m.version]; ok {
+		return s
+	}
+	if s, ok := m.dialects[driver]; ok {
+		return s
+	}
+	return script{up: m.up, down: m.down, requires: m.requires, active: m.active, fallback: m.fallback}
+}
+
+// available returns if the build of the database has got the module the script requires
+func (s script) available(tx *sqlx.Tx) (bool, error) {
+	if len(s.requires) == 0 {
+		return true, nil
+	}
+	var available bool
+	err := tx.Get(&available, s.requires)
+	return available, err
+}
+
+// migrations must be kept ordered by version, new migrations are appended to the end.
+// Already released migrations must never be changed.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create_scheduler",
+		up: `CREATE TABLE IF NOT EXISTS scheduler (id INTEGER PRIMARY KEY AUTOINCREMENT, date CHAR(8) NOT NULL DEFAULT "",
+	title VARCHAR(256) NOT NULL DEFAULT "", comment TEXT NOT NULL DEFAULT "", repeat VARCHAR(128) NOT NULL DEFAULT "");
+	CREATE INDEX IF NOT EXISTS scheduler_date ON scheduler (date)`,
+		down: `DROP INDEX IF EXISTS scheduler_date;
+	DROP TABLE IF EXISTS scheduler`,
+	},
+	{
+		version: 2,
+		name:    "widen_repeat_for_rrule",
+		// SQLite doesn't enforce varchar length, nothing to do there
+		up:   ``,
+		down: ``,
+		dialects: map[string]script{
+			postgres: {
+				up:   `ALTER TABLE scheduler ALTER COLUMN repeat TYPE VARCHAR(512)`,
+				down: `ALTER TABLE scheduler ALTER COLUMN repeat TYPE VARCHAR(128)`,
+			},
+		},
+	},
+	{
+		version: 3,
+		name:    "create_completions",
+		up: `CREATE TABLE completions (id INTEGER PRIMARY KEY AUTOINCREMENT, task_id INTEGER NOT NULL,
+	title VARCHAR(256) NOT NULL DEFAULT "", date CHAR(8) NOT NULL DEFAULT "", completed_at VARCHAR(32) NOT NULL DEFAULT "");
+	CREATE INDEX completions_task_id ON completions (task_id);
+	CREATE INDEX completions_completed_at ON completions (completed_at)`,
+		down: `DROP TABLE completions`,
+		dialects: map[string]script{
+			postgres: {
+				up: `CREATE TABLE completions (id SERIAL PRIMARY KEY, task_id INTEGER NOT NULL,
+	title VARCHAR(256) NOT NULL DEFAULT '', date CHAR(8) NOT NULL DEFAULT '', completed_at VARCHAR(32) NOT NULL DEFAULT '');
+	CREATE INDEX completions_task_id ON completions (task_id);
+	CREATE INDEX completions_completed_at ON completions (completed_at)`,
+				down: `DROP TABLE completions`,
+			},
+		},
+	},
+	{
+		version: 4,
+		name:    "add_scheduler_deleted_at",
+		up: `ALTER TABLE scheduler ADD COLUMN deleted_at VARCHAR(32) NOT NULL DEFAULT "";
+	CREATE INDEX scheduler_deleted_at ON scheduler (deleted_at)`,
+		down: `DROP INDEX scheduler_deleted_at;
+	ALTER TABLE scheduler DROP COLUMN deleted_at`,
+		dialects: map[string]script{
+			postgres: {
+				up: `ALTER TABLE scheduler ADD COLUMN deleted_at VARCHAR(32) NOT NULL DEFAULT '';
+	CREATE INDEX scheduler_deleted_at ON scheduler (deleted_at)`,
+				down: `DROP INDEX scheduler_deleted_at;
+	ALTER TABLE scheduler DROP COLUMN deleted_at`,
+			},
+		},
+	},
+	{
+		version: 5,
+		name:    "replace_scheduler_deleted_at_index",
+		// almost all tasks aren't deleted, so the full index made queries of the task list
+		// skip the scheduler_date index, the partial one is used by the trash only
+		up: `DROP INDEX scheduler_deleted_at;
+	CREATE INDEX scheduler_trash ON scheduler (deleted_at) WHERE deleted_at <> ''`,
+		down: `DROP INDEX scheduler_trash;
+	CREATE INDEX scheduler_deleted_at ON scheduler (deleted_at)`,
+	},
+	{
+		version: 6,
+		name:    "create_users",
+		// the default user owns the existing tasks, its password is set from the config on start
+		up: `CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, login VARCHAR(64) NOT NULL UNIQUE,
+	password_hash VARCHAR(128) NOT NULL DEFAULT "", created_at VARCHAR(32) NOT NULL DEFAULT "");
+	INSERT INTO users (id, login) VALUES (1, 'admin');
+	ALTER TABLE scheduler ADD COLUMN user_id INTEGER NOT NULL DEFAULT 1;
+	CREATE INDEX scheduler_user_date ON scheduler (user_id, date);
+	ALTER TABLE completions ADD COLUMN user_id INTEGER NOT NULL DEFAULT 1;
+	CREATE INDEX completions_user_completed_at ON completions (user_id, completed_at)`,
+		down: `DROP INDEX completions_user_completed_at;
+	ALTER TABLE completions DROP COLUMN user_id;
+	DROP INDEX scheduler_user_date;
+	ALTER TABLE scheduler DROP COLUMN user_id;
+	DROP TABLE users`,
+		dialects: map[string]script{
+			postgres: {
+				up: `CREATE TABLE users (id SERIAL PRIMARY KEY, login VARCHAR(64) NOT NULL UNIQUE,
+	password_hash VARCHAR(128) NOT NULL DEFAULT '', created_at VARCHAR(32) NOT NULL DEFAULT '');
+	INSERT INTO users (id, login) VALUES (1, 'admin');
+	SELECT setval('users_id_seq', 1);
+	ALTER TABLE scheduler ADD COLUMN user_id INTEGER NOT NULL DEFAULT 1;
+	CREATE INDEX scheduler_user_date ON scheduler (user_id, date);
+	ALTER TABLE completions ADD COLUMN user_id INTEGER NOT NULL DEFAULT 1;
+	CREATE INDEX completions_user_completed_at ON completions (user_id, completed_at)`,
+				down: `DROP INDEX completions_user_completed_at;
+	ALTER TABLE completions DROP COLUMN user_id;
+	DROP INDEX scheduler_user_date;
+	ALTER TABLE scheduler DROP COLUMN user_id;
+	DROP TABLE users`,
+			},
+		},
+	},
+	{
+		version: 7,
+		name:    "create_revoked_tokens",
+		up: `CREATE TABLE revoked_tokens (jti VARCHAR(64) PRIMARY KEY, user_id INTEGER NOT NULL,
+	expires_at VARCHAR(32) NOT NULL DEFAULT "");
+	CREATE INDEX revoked_tokens_expires_at ON revoked_tokens (expires_at)`,
+		down: `DROP TABLE revoked_tokens`,
+		dialects: map[string]script{
+			postgres: {
+				up: `CREATE TABLE revoked_tokens (jti VARCHAR(64) PRIMARY KEY, user_id INTEGER NOT NULL,
+	expires_at VARCHAR(32) NOT NULL DEFAULT '');
+	CREATE INDEX revoked_tokens_expires_at ON revoked_tokens (expires_at)`,
+				down: `DROP TABLE revoked_tokens`,
+			},
+		},
+	},
+	{
+		version: 8,
+		name:    "create_api_keys",
+		up: `CREATE TABLE api_keys (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INTEGER NOT NULL,
+	name VARCHAR(64) NOT NULL DEFAULT "", hint VARCHAR(32) NOT NULL DEFAULT "", key_hash VARCHAR(64) NOT NULL UNIQUE,
+	scope VARCHAR(16) NOT NULL DEFAULT "read", created_at VARCHAR(32) NOT NULL DEFAULT "",
+	expires_at VARCHAR(32) NOT NULL DEFAULT "");
+	CREATE INDEX api_keys_user_id ON api_keys (user_id)`,
+		down: `DROP TABLE api_keys`,
+		dialects: map[string]script{
+			postgres: {
+				up: `CREATE TABLE api_keys (id SERIAL PRIMARY KEY, user_id INTEGER NOT NULL,
+	name VARCHAR(64) NOT NULL DEFAULT '', hint VARCHAR(32) NOT NULL DEFAULT '', key_hash VARCHAR(64) NOT NULL UNIQUE,
+	scope VARCHAR(16) NOT NULL DEFAULT 'read', created_at VARCHAR(32) NOT NULL DEFAULT '',
+	expires_at VARCHAR(32) NOT NULL DEFAULT '');
+	CREATE INDEX api_keys_user_id ON api_keys (user_id)`,
+				down: `DROP TABLE api_keys`,
+			},
+		},
+	},
+	{
+		version: 9,
+		name:    "add_users_role",
+		// the default user is the administrator, the registered ones edit their tasks
+		up: `ALTER TABLE users ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'editor';
+	UPDATE users SET role = 'admin' WHERE id = 1`,
+		down: `ALTER TABLE users DROP COLUMN role`,
+	},
+	{
+		version: 10,
+		name:    "create_projects",
+		// every user gets the default project with its tasks and history, the user is its admin
+		up: `CREATE TABLE projects (id INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(128) NOT NULL DEFAULT "",
+	owner_id INTEGER NOT NULL, is_default INTEGER NOT NULL DEFAULT 0, created_at VARCHAR(32) NOT NULL DEFAULT "");
+	CREATE INDEX projects_owner_id ON projects (owner_id);
+	CREATE TABLE project_members (project_id INTEGER NOT NULL, user_id INTEGER NOT NULL,
+	role VARCHAR(16) NOT NULL DEFAULT 'editor', PRIMARY KEY (project_id, user_id));
+	CREATE INDEX project_members_user_id ON project_members (user_id);
+	INSERT INTO projects (name, owner_id, is_default, created_at) SELECT 'Default', id, 1, created_at FROM users ORDER BY id;
+	INSERT INTO project_members (project_id, user_id, role) SELECT id, owner_id, 'admin' FROM projects;
+	ALTER TABLE scheduler ADD COLUMN project_id INTEGER NOT NULL DEFAULT 0;
+	UPDATE scheduler SET project_id = (SELECT p.id FROM projects p WHERE p.owner_id = scheduler.user_id AND p.is_default = 1);
+	CREATE INDEX scheduler_project_date ON scheduler (project_id, date);
+	ALTER TABLE completions ADD COLUMN project_id INTEGER NOT NULL DEFAULT 0;
+	UPDATE completions SET project_id = (SELECT p.id FROM projects p WHERE p.owner_id = completions.user_id AND p.is_default = 1);
+	CREATE INDEX completions_project_completed_at ON completions (project_id, completed_at)`,
+		down: `DROP INDEX completions_project_completed_at;
+	ALTER TABLE completions DROP COLUMN project_id;
+	DROP INDEX scheduler_project_date;
+	ALTER TABLE scheduler DROP COLUMN project_id;
+	DROP TABLE project_members;
+	DROP TABLE projects`,
+		dialects: map[string]script{
+			postgres: {
+				up: `CREATE TABLE projects (id SERIAL PRIMARY KEY, name VARCHAR(128) NOT NULL DEFAULT '',
+	owner_id INTEGER NOT NULL, is_default BOOLEAN NOT NULL DEFAULT FALSE, created_at VARCHAR(32) NOT NULL DEFAULT '');
+	CREATE INDEX projects_owner_id ON projects (owner_id);
+	CREATE TABLE project_members (project_id INTEGER NOT NULL, user_id INTEGER NOT NULL,
+	role VARCHAR(16) NOT NULL DEFAULT 'editor', PRIMARY KEY (project_id, user_id));
+	CREATE INDEX project_members_user_id ON project_members (user_id);
+	INSERT INTO projects (name, owner_id, is_default, created_at) SELECT 'Default', id, TRUE, created_at FROM users ORDER BY id;
+	INSERT INTO project_members (project_id, user_id, role) SELECT id, owner_id, 'admin' FROM projects;
+	ALTER TABLE scheduler ADD COLUMN project_id INTEGER NOT NULL DEFAULT 0;
+	UPDATE scheduler SET project_id = (SELECT p.id FROM projects p WHERE p.owner_id = scheduler.user_id AND p.is_default);
+	CREATE INDEX scheduler_project_date ON scheduler (project_id, date);
+	ALTER TABLE completions ADD COLUMN project_id INTEGER NOT NULL DEFAULT 0;
+	UPDATE completions SET project_id = (SELECT p.id FROM projects p WHERE p.owner_id = completions.user_id AND p.is_default);
+	CREATE INDEX completions_project_completed_at ON completions (project_id, completed_at)`,
+				down: `DROP INDEX completions_project_completed_at;
+	ALTER TABLE completions DROP COLUMN project_id;
+	DROP INDEX scheduler_project_date;
+	ALTER TABLE scheduler DROP COLUMN project_id;
+	DROP TABLE project_members;
+	DROP TABLE projects`,
+			},
+		},
+	},
+	{
+		version: 11,
+		name:    "create_tags",
+		up: `CREATE TABLE tags (id INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(64) NOT NULL UNIQUE);
+	CREATE TABLE task_tags (task_id INTEGER NOT NULL, tag_id INTEGER NOT NULL, PRIMARY KEY (task_id, tag_id));
+	CREATE INDEX task_tags_tag_id ON task_tags (tag_id)`,
+		down: `DROP TABLE task_tags;
+	DROP TABLE tags`,
+		dialects: map[string]script{
+			postgres: {
+				up: `CREATE TABLE tags (id SERIAL PRIMARY KEY, name VARCHAR(64) NOT NULL UNIQUE);
+	CREATE TABLE task_tags (task_id INTEGER NOT NULL, tag_id INTEGER NOT NULL, PRIMARY KEY (task_id, tag_id));
+	CREATE INDEX task_tags_tag_id ON task_tags (tag_id)`,
+				down: `DROP TABLE task_tags;
+	DROP TABLE tags`,
+			},
+		},
+	},
+	{
+		version: 12,
+		name:    "add_scheduler_priority",
+		up:      `ALTER TABLE scheduler ADD COLUMN priority INTEGER NOT NULL DEFAULT 4`,
+		down:    `ALTER TABLE scheduler DROP COLUMN priority`,
+	},
+	{
+		version: 13,
+		name:    "add_time_and_timezone",
+		// the existing tasks take the whole day in the zone of their user, which is the local zone of the server
+		up: `ALTER TABLE scheduler ADD COLUMN time_of_day VARCHAR(5) NOT NULL DEFAULT "";
+	ALTER TABLE scheduler ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT "";
+	ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT ""`,
+		down: `ALTER TABLE users DROP COLUMN timezone;
+	ALTER TABLE scheduler DROP COLUMN timezone;
+	ALTER TABLE scheduler DROP COLUMN time_of_day`,
+		dialects: map[string]script{
+			postgres: {
+				up: `ALTER TABLE scheduler ADD COLUMN time_of_day VARCHAR(5) NOT NULL DEFAULT '';
+	ALTER TABLE scheduler ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT ''`,
+				down: `ALTER TABLE users DROP COLUMN timezone;
+	ALTER TABLE scheduler DROP COLUMN timezone;
+	ALTER TABLE scheduler DROP COLUMN time_of_day`,
+			},
+		},
+	},
+	{
+		version: 14,
+		name:    "create_reminders",
+		up: `ALTER TABLE scheduler ADD COLUMN reminders VARCHAR(128) NOT NULL DEFAULT "";
+	CREATE TABLE reminder_deliveries (task_id INTEGER NOT NULL, remind_at VARCHAR(32) NOT NULL, channel VARCHAR(16) NOT NULL,
+	sent_at VARCHAR(32) NOT NULL DEFAULT "", attempts INTEGER NOT NULL DEFAULT 0, error TEXT NOT NULL DEFAULT "",
+	PRIMARY KEY (task_id, remind_at, channel));
+	CREATE INDEX reminder_deliveries_remind_at ON reminder_deliveries (remind_at)`,
+		down: `DROP TABLE reminder_deliveries;
+	ALTER TABLE scheduler DROP COLUMN reminders`,
+		dialects: map[string]script{
+			postgres: {
+				up: `ALTER TABLE scheduler ADD COLUMN reminders VARCHAR(128) NOT NULL DEFAULT '';
+	CREATE TABLE reminder_deliveries (task_id INTEGER NOT NULL, remind_at VARCHAR(32) NOT NULL, channel VARCHAR(16) NOT NULL,
+	sent_at VARCHAR(32) NOT NULL DEFAULT '', attempts INTEGER NOT NULL DEFAULT 0, error TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (task_id, remind_at, channel));
+	CREATE INDEX reminder_deliveries_remind_at ON reminder_deliveries (remind_at)`,
+				down: `DROP TABLE reminder_deliveries;
+	ALTER TABLE scheduler DROP COLUMN reminders`,
+			},
+		},
+	},
+	{
+		version: 15,
+		name:    "create_task_items",
+		up: `CREATE TABLE task_items (id INTEGER PRIMARY KEY AUTOINCREMENT, task_id INTEGER NOT NULL,
+	title VARCHAR(256) NOT NULL DEFAULT "", done INTEGER NOT NULL DEFAULT 0);
+	CREATE INDEX task_items_task_id ON task_items (task_id)`,
+		down: `DROP TABLE task_items`,
+		dialects: map[string]script{
+			postgres: {
+				up: `CREATE TABLE task_items (id SERIAL PRIMARY KEY, task_id INTEGER NOT NULL,
+	title VARCHAR(256) NOT NULL DEFAULT '', done BOOLEAN NOT NULL DEFAULT FALSE);
+	CREATE INDEX task_items_task_id ON task_items (task_id)`,
+				down: `DROP TABLE task_items`,
+			},
+		},
+	},
+	{
+		version: 16,
+		name:    "create_task_dependencies",
+		up: `CREATE TABLE task_dependencies (task_id INTEGER NOT NULL, blocker_id INTEGER NOT NULL,
+	PRIMARY KEY (task_id, blocker_id));
+	CREATE INDEX task_dependencies_blocker_id ON task_dependencies (blocker_id)`,
+		down: `DROP TABLE task_dependencies`,
+	},
+	{
+		version: 17,
+		name:    "add_scheduler_version",
+		up:      `ALTER TABLE scheduler ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+		down:    `ALTER TABLE scheduler DROP COLUMN version`,
+	},
+	{
+		version: 18,
+		name:    "create_audit_log",
+		up: `CREATE TABLE audit_log (id INTEGER PRIMARY KEY AUTOINCREMENT, created_at VARCHAR(32) NOT NULL DEFAULT "",
+	actor_id INTEGER NOT NULL DEFAULT 0, actor TEXT NOT NULL DEFAULT "", remote_addr VARCHAR(64) NOT NULL DEFAULT "",
+	endpoint TEXT NOT NULL DEFAULT "", action VARCHAR(16) NOT NULL DEFAULT "", task_id INTEGER NOT NULL DEFAULT 0,
+	status INTEGER NOT NULL DEFAULT 0, error TEXT NOT NULL DEFAULT "", diff TEXT NOT NULL DEFAULT "");
+	CREATE INDEX audit_log_task_id ON audit_log (task_id);
+	CREATE INDEX audit_log_actor ON audit_log (actor);
+	CREATE INDEX audit_log_created_at ON audit_log (created_at)`,
+		down: `DROP TABLE audit_log`,
+		dialects: map[string]script{
+			postgres: {
+				up: `CREATE TABLE audit_log (id SERIAL PRIMARY KEY, created_at VARCHAR(32) NOT NULL DEFAULT '',
+	actor_id INTEGER NOT NULL DEFAULT 0, actor TEXT NOT NULL DEFAULT '', remote_addr VARCHAR(64) NOT NULL DEFAULT '',
+	endpoint TEXT NOT NULL DEFAULT '', action VARCHAR(16) NOT NULL DEFAULT '', task_id INTEGER NOT NULL DEFAULT 0,
+	status INTEGER NOT NULL DEFAULT 0, error TEXT NOT NULL DEFAULT '', diff TEXT NOT NULL DEFAULT '');
+	CREATE INDEX audit_log_task_id ON audit_log (task_id);
+	CREATE INDEX audit_log_actor ON audit_log (actor);
+	CREATE INDEX audit_log_created_at ON audit_log (created_at)`,
+				down: `DROP TABLE audit_log`,
+			},
+		},
+	},
+	{
+		version: 19,
+		name:    "create_scheduler_fts",
+		// FTS5 is compiled in by the sqlite_fts5 build tag of go-sqlite3, the index is external content one:
+		// it keeps only the tokens of title and comment and is updated by the storage of the FTS5 build along with
+		// the tasks. There are no triggers, so the build without FTS5 can change the tasks of the same database,
+		// the FTS5 build rebuilds the index on start to catch up with its changes.
+		up: `CREATE VIRTUAL TABLE IF NOT EXISTS scheduler_fts USING fts5(title, comment,
+	content='scheduler', content_rowid='id', tokenize='unicode61 remove_diacritics 2');
+	INSERT INTO scheduler_fts (scheduler_fts) VALUES ('rebuild')`,
+		down:     `DROP TABLE IF EXISTS scheduler_fts`,
+		requires: `SELECT sqlite_compileoption_used('ENABLE_FTS5')`,
+		active:   ftsActive,
+		//there is no fallback, the virtual table can't be dropped without its module, it's left unused till FTS5 build rebuilds it
+		dialects: map[string]script{
+			//full text search is provided for SQLite only
+			postgres: {},
+		},
+	},
+	{
+		version: 20,
+		name:    "add_api_keys_role",
+		// the role granted to the key on creation, the write keys of the viewers never granted more than the viewer
+		up: `ALTER TABLE api_keys ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'viewer';
+	UPDATE api_keys SET role = 'editor' WHERE scope = 'write' AND user_id IN (SELECT id FROM users WHERE role <> 'viewer')`,
+		down: `ALTER TABLE api_keys DROP COLUMN role`,
+	},
+	{
+		version: 21,
+		name:    "drop_scheduler_fts_triggers",
+		// the triggers created by the first version of create_scheduler_fts broke every change of the tasks
+		// made by the build without FTS5, the index is kept in sync by the storage now
+		up: `DROP TRIGGER IF EXISTS scheduler_fts_insert;
+	DROP TRIGGER IF EXISTS scheduler_fts_delete;
+	DROP TRIGGER IF EXISTS scheduler_fts_update`,
+		down: ``,
+		dialects: map[string]script{
+			postgres: {},
+		},
+	},
+}
+
+type MigrationStatus struct {
+	Version   int    `db:"version"`
+	Name      string `db:"name"`
+	AppliedAt string `db:"applied_at"`
+}
+
+func createMigrationsTable(Db *sqlx.DB) error {
+	schema := `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY,
+	name VARCHAR(256) NOT NULL DEFAULT '', applied_at VARCHAR(32) NOT NULL DEFAULT '')`
+	_, err := Db.Exec(schema)
+	return err
+}
+
+func appliedVersions(Db *sqlx.DB) (map[int]MigrationStatus, error) {
+	if err := createMigrationsTable(Db); err != nil {
+		return nil, err
+	}
+	applied := []MigrationStatus{}
+	err := Db.Select(&applied, `SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[int]MigrationStatus, len(applied))
+	for _, m := range applied {
+		result[m.Version] = m
+	}
+	return result, nil
+}
+
+// MigrateUp applies pending migrations in ascending order, every migration in its own transaction.
+// If steps <= 0 all pending migrations are applied. The optional migrations already applied are
+// synced with the build of the database.
+func MigrateUp(Db *sqlx.DB, steps int) error {
+	applied, err := appliedVersions(Db)
+	if err != nil {
+		return err
+	}
+	done := 0
+	for _, m := range migrations {
+		if _, ok := applied[m.version]; ok {
+			if err := syncOptional(Db, m); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", m.version, m.name, err)
+			}
+			continue
+		}
+		if steps > 0 && done == steps {
+			break
+		}
+		log.Printf("Applying migration %d_%s\n", m.version, m.name)
+		err := inTx(Db, func(tx *sqlx.Tx) error {
+			s := m.scripts(Db.DriverName())
+			available, err := s.available(tx)
+			if err != nil {
+				return err
+			}
+			up := s.up
+			if !available {
+				log.Printf("Migration %d_%s isn't supported by the build of the database, it's applied without changes\n", m.version, m.name)
+				up = s.fallback
+			}
+			if err := execScript(tx, up); err != nil {
+				return err
+			}
+			_, err = tx.Exec(tx.Rebind(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`),
+				m.version, m.name, time.Now().UTC().Format(time.RFC3339))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d_%s failed: %w", m.version, m.name, err)
+		}
+		done++
+	}
+	return nil
+}
+
+// MigrateDown rolls back applied migrations in descending order, every migration in its own transaction.
+// If steps <= 0 only the last applied migration is rolled back.
+func MigrateDown(Db *sqlx.DB, steps int) error {
+	applied, err := appliedVersions(Db)
+	if err != nil {
+		return err
+	}
+	if steps <= 0 {
+		steps = 1
+	}
+	done := 0
+	for i := len(migrations) - 1; i >= 0 && done < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.version]; !ok {
+			continue
+		}
+		log.Printf("Rolling back migration %d_%s\n", m.version, m.name)
+		err := inTx(Db, func(tx *sqlx.Tx) error {
+			s := m.scripts(Db.DriverName())
+			available, err := s.available(tx)
+			if err != nil {
+				return err
+			}
+			//down of the optional migration needs the module as well as up
+			down := s.down
+			if !available {
+				down = s.fallback
+			}
+			if err := execScript(tx, down); err != nil {
+				return err
+			}
+			_, err = tx.Exec(tx.Rebind(`DELETE FROM schema_migrations WHERE version = ?`), m.version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("rollback of migration %d_%s failed: %w", m.version, m.name, err)
+		}
+		done++
+	}
+	return nil
+}
+
+// syncOptional applies up of the applied optional migration if the build has got the module it requires
+// and up hasn't taken effect, or runs fallback, if there is one, when the build has lost the module and up has taken effect
+func syncOptional(Db *sqlx.DB, m migration) error {
+	s := m.scripts(Db.DriverName())
+	if len(s.requires) == 0 {
+		return nil
+	}
+	return inTx(Db, func(tx *sqlx.Tx) error {
+		var active bool
+		if err := tx.Get(&active, s.active); err != nil {
+			return err
+		}
+		available, err := s.available(tx)
+		if err != nil {
+			return err
+		}
+		switch {
+		case available && !active:
+			log.Printf("Applying migration %d_%s supported by the build of the database\n", m.version, m.name)
+			return execScript(tx, s.up)
+		case !available && active && len(strings.TrimSpace(s.fallback)) > 0:
+			log.Printf("Migration %d_%s isn't supported by the build of the database, its changes are rolled back\n", m.version, m.name)
+			return execScript(tx, s.fallback)
+		}
+		return nil
+	})
+}
+
+// Migrations returns status of all known migrations, AppliedAt is empty for pending ones.
+func Migrations(Db *sqlx.DB) ([]MigrationStatus, error) {
+	applied, err := appliedVersions(Db)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.version, Name: m.name}
+		if a, ok := applied[m.version]; ok {
+			status.AppliedAt = a.AppliedAt
+		}
+		result = append(result, status)
+	}
+	return result, nil
+}
+
+func execScript(tx *sqlx.Tx, script string) error {
+	if len(strings.TrimSpace(script)) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(script)
+	return err
+}
+
+func inTx(Db *sqlx.DB, fn func(tx *sqlx.Tx) error) error {
+	tx, err := Db.Beginx()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,22 @@
+package storage
+
+import (
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+type PostgresStorage struct {
+	sqlStorage
+}
+
+func InitPostgres(dsn string) (*sqlx.DB, error) {
+	log.Println("Connecting to Postgres Db")
+	Db, err := sqlx.Connect("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return Db, nil
+}
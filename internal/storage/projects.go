@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/project"
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const projectColumns = `p.id, p.name, p.owner_id, p.is_default, p.created_at, m.role`
+
+// ProjectRepository keeps projects of the user, the projects are returned with the role of the user in them.
+// SetMember and DeleteMember aren't scoped by ForUser, the caller checks the role of the user in the project.
+type ProjectRepository interface {
+	// CreateProject makes the user the owner and the admin of the project
+	CreateProject(p *project.Project) (int, error)
+	GetProjects() ([]project.Project, error)
+	GetProject(id int) (*project.Project, error)
+	GetDefaultProject() (*project.Project, error)
+	UpdateProject(p *project.Project) error
+	// DeleteProject deletes the project with its tasks and history
+	DeleteProject(id int) error
+	GetMembers(projectId int) ([]project.Member, error)
+	SetMember(projectId int, userId int, role string) error
+	DeleteMember(projectId int, userId int) error
+	// GetTaskRole returns the role of the user in the project of the task, the task may be in the trash
+	GetTaskRole(taskId int) (string, error)
+}
+
+func (t sqlStorage) CreateProject(p *project.Project) (int, error) {
+	var id int
+	err := t.withTx(func(bound sqlStorage) error {
+		insertRow := `INSERT INTO projects (name, owner_id, is_default, created_at) VALUES (?, ?, ?, ?) RETURNING id`
+		err := sqlx.Get(bound.conn(), &id, t.Db.Rebind(insertRow),
+			p.Name, t.userId, p.IsDefault, time.Now().UTC().Format(time.RFC3339))
+		if err != nil {
+			return err
+		}
+		return bound.SetMember(id, t.userId, user.RoleAdmin)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (t sqlStorage) GetProjects() ([]project.Project, error) {
+	projects := []project.Project{}
+	selectRows := `SELECT ` + projectColumns + ` FROM projects p JOIN project_members m ON m.project_id = p.id
+	WHERE m.user_id = ? ORDER BY p.id`
+	if err := sqlx.Select(t.conn(), &projects, t.Db.Rebind(selectRows), t.userId); err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+func (t sqlStorage) GetProject(id int) (*project.Project, error) {
+	p := &project.Project{}
+	selectRow := `SELECT ` + projectColumns + ` FROM projects p JOIN project_members m ON m.project_id = p.id
+	WHERE m.user_id = ? AND p.id = ?`
+	if err := sqlx.Get(t.conn(), p, t.Db.Rebind(selectRow), t.userId, id); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (t sqlStorage) GetDefaultProject() (*project.Project, error) {
+	p := &project.Project{}
+	selectRow := `SELECT ` + projectColumns + ` FROM projects p JOIN project_members m ON m.project_id = p.id
+	WHERE m.user_id = ? AND p.owner_id = ? AND p.is_default = ?`
+	if err := sqlx.Get(t.conn(), p, t.Db.Rebind(selectRow), t.userId, t.userId, true); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (t sqlStorage) UpdateProject(p *project.Project) error {
+	updateRow := `UPDATE projects SET name = ? WHERE id = ? AND id IN (SELECT project_id FROM project_members WHERE user_id = ?)`
+	res, err := t.conn().Exec(t.Db.Rebind(updateRow), p.Name, p.Id, t.userId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (t sqlStorage) DeleteProject(id int) error {
+	return t.withTx(func(bound sqlStorage) error {
+		if _, err := bound.GetProject(id); err != nil {
+			return err
+		}
+		if err := bound.deleteTaskRows(`project_id = ?`, id); err != nil {
+			return err
+		}
+		for _, deleteRows := range []string{
+			`DELETE FROM completions WHERE project_id = ?`,
+			`DELETE FROM scheduler WHERE project_id = ?`,
+			`DELETE FROM project_members WHERE project_id = ?`,
+			`DELETE FROM projects WHERE id = ?`,
+		} {
+			if _, err := bound.conn().Exec(t.Db.Rebind(deleteRows), id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (t sqlStorage) GetMembers(projectId int) ([]project.Member, error) {
+	members := []project.Member{}
+	selectRows := `SELECT m.project_id, m.user_id, u.login, m.role FROM project_members m JOIN users u ON u.id = m.user_id
+	WHERE m.project_id = ? AND ` + memberProjects + ` ORDER BY m.user_id`
+	if err := sqlx.Select(t.conn(), &members, t.Db.Rebind(selectRows), projectId, t.userId); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (t sqlStorage) SetMember(projectId int, userId int, role string) error {
+	upsertRow := `INSERT INTO project_members (project_id, user_id, role) VALUES (?, ?, ?)
+	ON CONFLICT (project_id, user_id) DO UPDATE SET role = excluded.role`
+	_, err := t.conn().Exec(t.Db.Rebind(upsertRow), projectId, userId, role)
+	return err
+}
+
+func (t sqlStorage) DeleteMember(projectId int, userId int) error {
+	deleteRow := `DELETE FROM project_members WHERE project_id = ? AND user_id = ?`
+	res, err := t.conn().Exec(t.Db.Rebind(deleteRow), projectId, userId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (t sqlStorage) GetTaskRole(taskId int) (string, error) {
+	var role string
+	selectRow := `SELECT m.role FROM scheduler s JOIN project_members m ON m.project_id = s.project_id
+	WHERE s.id = ? AND m.user_id = ?`
+	if err := sqlx.Get(t.conn(), &role, t.Db.Rebind(selectRow), taskId, t.userId); err != nil {
+		return "", err
+	}
+	return role, nil
+}
@@ -0,0 +1,412 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+)
+
+// TaskQuery selects a page of tasks ordered by the sort key and id
+type TaskQuery struct {
+	// Search is a text to find in title or comment, or a date in the 02.01.2006 format
+	Search string
+	// Cursor is the opaque position returned as TaskPage.NextCursor by the previous page
+	Cursor string
+	// Limit of the page size, cfg.Limit is used if it isn't set
+	Limit int
+	// From and To limit the task date inclusively, both are in the 20060102 format
+	From string
+	To   string
+	// Repeat filters tasks by the repeat rule: RepeatNone, RepeatAny or the kind of the rule d, w, m or y
+	Repeat string
+	// Overdue selects tasks with the date before today or with the passed time today
+	Overdue bool
+	// Now is the current time in the time zone of the user, the local time of the server is used if it isn't set.
+	// Today of the tasks with their own time zone is found in that zone.
+	Now time.Time
+	// Project selects tasks of the project, tasks of all projects of the user are selected if it isn't set
+	Project int
+	// Tags selects tasks marked by all of the tags, or by any of them if AnyTag is set
+	Tags   []string
+	AnyTag bool
+	// Sort is the key the tasks are ordered by: SortDate, SortPriority, SortTitle or SortCreated.
+	// The date is the default, but the full text search orders the tasks by relevance if it isn't set.
+	Sort string
+	// Desc reverses the order of the sort key, tasks with the same key are always ordered by id
+	Desc bool
+
+	// userId is set by the repository the query is made with
+	userId int
+	// zones are the time zones of the tasks of the user, they are set by the repository for the overdue filter
+	zones []string
+}
+
+const (
+	RepeatNone = "none"
+	RepeatAny  = "any"
+)
+
+const (
+	SortDate     = "date"
+	SortPriority = "priority"
+	SortTitle    = "title"
+	SortCreated  = "created"
+)
+
+// sortColumns maps the sort keys to the columns of the scheduler table, ids grow with the creation of tasks.
+// The columns are compared one by one rather than as an expression, so the index of the columns is used.
+var sortColumns = map[string][]string{
+	SortDate:     {"date", "time_of_day"},
+	SortPriority: {"priority"},
+	SortTitle:    {"title"},
+	SortCreated:  {"id"},
+}
+
+// repeatFrequencies maps the kinds of the compact rules to the RRULE frequencies
+var repeatFrequencies = map[string]string{
+	"d": "DAILY",
+	"w": "WEEKLY",
+	"m": "MONTHLY",
+	"y": "YEARLY",
+}
+
+type TaskPage struct {
+	Tasks      []task.Task `json:"tasks"`
+	NextCursor string      `json:"next_cursor"`
+	// Total is the number of tasks matching the query on all pages
+	Total int `json:"total"`
+}
+
+// ErrInvalidCursor is returned for the cursor which wasn't made by GetTasks
+var ErrInvalidCursor = errors.New("incorrect cursor")
+
+// cursor is the position of the last task of the page in the (key, id) order,
+// key is the sort key or the relevance rank for the full text search
+type cursor struct {
+	key string
+	id  int
+	// keys are the values of the sort columns the key is split to
+	keys []string
+}
+
+func encodeCursor(key string, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key + "|" + id))
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	//the key may be a title with the separator, id is after the last one
+	sep := strings.LastIndex(string(raw), "|")
+	if sep < 0 {
+		return nil, ErrInvalidCursor
+	}
+	key, idRaw := string(raw[:sep]), string(raw[sep+1:])
+	id, err := strconv.Atoi(idRaw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor{key: key, id: id}, nil
+}
+
+// split splits the key to the values of the sort columns of the query, they are joined by the separator
+// as well, the only column may have it in its value
+func (c *cursor) split(q TaskQuery) error {
+	columns := q.sortColumns()
+	c.keys = strings.SplitN(c.key, "|", len(columns))
+	if len(c.keys) != len(columns) {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+func (q TaskQuery) limit() int {
+	if q.Limit > 0 {
+		return q.Limit
+	}
+	return cfg.Limit
+}
+
+// Validate checks the filters of the query
+func (q TaskQuery) Validate() error {
+	if len(q.From) > 0 {
+		if _, err := time.Parse("20060102", q.From); err != nil {
+			return fmt.Errorf("incorrect from date %q, expected 20060102 format", q.From)
+		}
+	}
+	if len(q.To) > 0 {
+		if _, err := time.Parse("20060102", q.To); err != nil {
+			return fmt.Errorf("incorrect to date %q, expected 20060102 format", q.To)
+		}
+	}
+	if _, ok := repeatFrequencies[q.Repeat]; !ok && len(q.Repeat) > 0 && q.Repeat != RepeatNone && q.Repeat != RepeatAny {
+		return fmt.Errorf("incorrect repeat filter %q, expected none, any, d, w, m or y", q.Repeat)
+	}
+	if _, ok := sortColumns[q.Sort]; !ok && len(q.Sort) > 0 {
+		return fmt.Errorf("incorrect sort %q, expected date, priority, title or created", q.Sort)
+	}
+	return nil
+}
+
+// sortColumns returns the columns the tasks are ordered by, the date is the default
+func (q TaskQuery) sortColumns() []string {
+	if columns, ok := sortColumns[q.Sort]; ok {
+		return columns
+	}
+	return sortColumns[SortDate]
+}
+
+// orderBy returns ORDER BY clause of the sort key, the ties are broken by id
+func (q TaskQuery) orderBy() string {
+	order := ""
+	for _, column := range q.sortColumns() {
+		order += column
+		if q.Desc {
+			order += ` DESC`
+		}
+		order += `, `
+	}
+	return order + `id`
+}
+
+// afterCursor returns the condition selecting the tasks after the split cursor with its arguments,
+// the columns of the key are compared in turn and the ties are broken by id
+func (q TaskQuery) afterCursor(c *cursor) (string, []any) {
+	op := ">"
+	if q.Desc {
+		op = "<"
+	}
+	cond, args := `id > ?`, []any{c.id}
+	columns := q.sortColumns()
+	for i := len(columns) - 1; i >= 0; i-- {
+		cond = fmt.Sprintf(`(%[1]s %[2]s ? OR (%[1]s = ? AND %[3]s))`, columns[i], op, cond)
+		args = append([]any{c.keys[i], c.keys[i]}, args...)
+	}
+	//the bound of the first column alone lets the index of the column skip the previous pages
+	cond = fmt.Sprintf(`(%s %s= ? AND %s)`, columns[0], op, cond)
+	return cond, append([]any{c.keys[0]}, args...)
+}
+
+// sortKey returns the value of the sort key of the task kept in the cursor, the values of the columns
+// are joined by the separator
+func (q TaskQuery) sortKey(t task.Task) string {
+	return strings.Join(q.sortKeys(t), "|")
+}
+
+// sortKeys returns the values of the sort columns of the task
+func (q TaskQuery) sortKeys(t task.Task) []string {
+	switch q.Sort {
+	case SortPriority:
+		return []string{t.Priority}
+	case SortTitle:
+		return []string{t.Title}
+	case SortCreated:
+		return []string{t.Id}
+	default:
+		return []string{t.Date, t.Time}
+	}
+}
+
+// compareKeys compares the sort keys column by column the way the database does, priorities and ids are numbers
+func (q TaskQuery) compareKeys(a []string, b []string) int {
+	for i := range a {
+		c := strings.Compare(a[i], b[i])
+		if q.Sort == SortPriority || q.Sort == SortCreated {
+			numA, _ := strconv.Atoi(a[i])
+			numB, _ := strconv.Atoi(b[i])
+			c = numA - numB
+		}
+		if c != 0 && q.Desc {
+			return -c
+		} else if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// less orders the tasks by the sort key and id the same way as orderBy
+func (q TaskQuery) less(a task.Task, b task.Task) bool {
+	if c := q.compareKeys(q.sortKeys(a), q.sortKeys(b)); c != 0 {
+		return c < 0
+	}
+	idA, _ := strconv.Atoi(a.Id)
+	idB, _ := strconv.Atoi(b.Id)
+	return idA < idB
+}
+
+// filters returns WHERE conditions of the query except of the text search and the cursor with their arguments
+func (q TaskQuery) filters() ([]string, []any) {
+	conds := []string{memberProjects, `deleted_at = ''`}
+	args := []any{q.userId}
+	if q.Project > 0 {
+		conds = append(conds, `project_id = ?`)
+		args = append(args, q.Project)
+	}
+	if _, ok := q.text(); !ok && len(q.Search) > 0 {
+		conds = append(conds, `date = ?`)
+		args = append(args, q.date())
+	}
+	//dates are compared as strings, so the conditions use the scheduler_date index
+	if len(q.From) > 0 {
+		conds = append(conds, `date >= ?`)
+		args = append(args, q.From)
+	}
+	if len(q.To) > 0 {
+		conds = append(conds, `date <= ?`)
+		args = append(args, q.To)
+	}
+	if q.Overdue {
+		cond, condArgs := overdueCondition(q.now())
+		zoneConds := []string{`(timezone = '' AND ` + cond + `)`}
+		args = append(args, condArgs...)
+		for _, zone := range q.zones {
+			loc, err := task.LoadZone(zone)
+			if err != nil {
+				continue
+			}
+			cond, condArgs := overdueCondition(q.now().In(loc))
+			zoneConds = append(zoneConds, `(timezone = ? AND `+cond+`)`)
+			args = append(append(args, zone), condArgs...)
+		}
+		conds = append(conds, `(`+strings.Join(zoneConds, " OR ")+`)`)
+	}
+	if tags := q.tags(); len(tags) > 0 {
+		cond := `id IN (SELECT tt.task_id FROM task_tags tt JOIN tags g ON g.id = tt.tag_id WHERE g.name IN (` +
+			strings.TrimSuffix(strings.Repeat("?, ", len(tags)), ", ") + `)`
+		for _, tag := range tags {
+			args = append(args, tag)
+		}
+		if !q.AnyTag {
+			cond += ` GROUP BY tt.task_id HAVING COUNT(*) = ?`
+			args = append(args, len(tags))
+		}
+		conds = append(conds, cond+`)`)
+	}
+	switch q.Repeat {
+	case "":
+	case RepeatNone:
+		conds = append(conds, `repeat = ''`)
+	case RepeatAny:
+		conds = append(conds, `repeat <> ''`)
+	default:
+		conds = append(conds, `(repeat = ? OR repeat LIKE ? OR UPPER(repeat) LIKE ?)`)
+		args = append(args, q.Repeat, q.Repeat+" %", "%FREQ="+repeatFrequencies[q.Repeat]+"%")
+	}
+	return conds, args
+}
+
+// conditions returns WHERE conditions of the query except of the cursor with their arguments
+func (q TaskQuery) conditions() ([]string, []any) {
+	conds, args := q.filters()
+	if text, ok := q.text(); ok {
+		search := "%" + strings.ToUpper(text) + "%"
+		conds = append(conds, `(UPPER(title) LIKE ? OR UPPER(comment) LIKE ?)`)
+		args = append(args, search, search)
+	}
+	return conds, args
+}
+
+// tags returns the lowercased tags of the filter without duplicates
+func (q TaskQuery) tags() []string {
+	tags := make([]string, 0, len(q.Tags))
+	for _, tag := range q.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if len(tag) > 0 && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// text returns the search text if the search isn't a date
+func (q TaskQuery) text() (string, bool) {
+	if len(q.Search) == 0 {
+		return "", false
+	}
+	if _, err := time.Parse("02.01.2006", q.Search); err == nil {
+		return "", false
+	}
+	return q.Search, true
+}
+
+// date returns the searched date in the tasks format, the search must be a date
+func (q TaskQuery) date() string {
+	date, _ := time.Parse("02.01.2006", q.Search)
+	return date.Format("20060102")
+}
+
+func (q TaskQuery) now() time.Time {
+	if q.Now.IsZero() {
+		return time.Now()
+	}
+	return q.Now
+}
+
+// overdueCondition selects the tasks overdue at now the same way as task.Overdue, the tasks without time
+// are overdue only the next day
+func overdueCondition(now time.Time) (string, []any) {
+	today := now.Format("20060102")
+	return `(date < ? OR (date = ? AND time_of_day <> '' AND time_of_day < ?))`,
+		[]any{today, today, now.Format(task.TimeFormat)}
+}
+
+// match checks the task against the query the same way conditions do in SQL
+func (q TaskQuery) match(t task.Task) bool {
+	if len(t.DeletedAt) > 0 || (q.Project > 0 && t.ProjectId != strconv.Itoa(q.Project)) {
+		return false
+	}
+	if text, ok := q.text(); ok {
+		search := strings.ToUpper(text)
+		if !strings.Contains(strings.ToUpper(t.Title), search) && !strings.Contains(strings.ToUpper(t.Comment), search) {
+			return false
+		}
+	} else if len(q.Search) > 0 && t.Date != q.date() {
+		return false
+	}
+	if (len(q.From) > 0 && t.Date < q.From) || (len(q.To) > 0 && t.Date > q.To) {
+		return false
+	}
+	if q.Overdue && !t.Overdue(q.now()) {
+		return false
+	}
+	if tags := q.tags(); len(tags) > 0 {
+		matched := 0
+		for _, tag := range tags {
+			if slices.Contains(t.Tags, tag) {
+				matched++
+			}
+		}
+		if matched == 0 || (!q.AnyTag && matched < len(tags)) {
+			return false
+		}
+	}
+	switch q.Repeat {
+	case "":
+		return true
+	case RepeatNone:
+		return len(t.Repeat) == 0
+	case RepeatAny:
+		return len(t.Repeat) > 0
+	default:
+		return t.Repeat == q.Repeat || strings.HasPrefix(t.Repeat, q.Repeat+" ") ||
+			strings.Contains(strings.ToUpper(t.Repeat), "FREQ="+repeatFrequencies[q.Repeat])
+	}
+}
+
+// after checks that the task follows the split cursor in the order of the query
+func (c *cursor) after(q TaskQuery, t task.Task) bool {
+	if k := q.compareKeys(q.sortKeys(t), c.keys); k != 0 {
+		return k > 0
+	}
+	id, _ := strconv.Atoi(t.Id)
+	return id > c.id
+}
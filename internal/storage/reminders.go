@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Delivery is the state of the reminder of the task sent through the channel. RemindAt is the moment
+// of the reminder and SentAt is set when the reminder is delivered, both in the time.RFC3339 format of UTC.
+type Delivery struct {
+	TaskId   int    `db:"task_id"`
+	RemindAt string `db:"remind_at"`
+	Channel  string `db:"channel"`
+	SentAt   string `db:"sent_at"`
+	Attempts int    `db:"attempts"`
+	Error    string `db:"error"`
+}
+
+// ReminderRepository keeps the delivery state of the reminders, so they aren't sent again after restart.
+// It isn't scoped by ForUser, the reminders of all users are sent by the scheduler.
+type ReminderRepository interface {
+	// GetReminderTasks returns the tasks of all users not in the trash with the date from from to to inclusive
+	GetReminderTasks(from string, to string) ([]task.Task, error)
+	GetDelivery(taskId int, remindAt string, channel string) (*Delivery, error)
+	SaveDelivery(d *Delivery) error
+	// PurgeDeliveries deletes the state of the reminders planned before the timestamp
+	PurgeDeliveries(before string) (int, error)
+}
+
+func (t sqlStorage) GetReminderTasks(from string, to string) ([]task.Task, error) {
+	tasks := []task.Task{}
+	selectRows := `SELECT ` + taskColumns + ` FROM scheduler WHERE deleted_at = '' AND date >= ? AND date <= ? ORDER BY date, id`
+	if err := sqlx.Select(t.conn(), &tasks, t.Db.Rebind(selectRows), from, to); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (t sqlStorage) GetDelivery(taskId int, remindAt string, channel string) (*Delivery, error) {
+	d := &Delivery{}
+	selectRow := `SELECT task_id, remind_at, channel, sent_at, attempts, error FROM reminder_deliveries
+	WHERE task_id = ? AND remind_at = ? AND channel = ?`
+	if err := sqlx.Get(t.conn(), d, t.Db.Rebind(selectRow), taskId, remindAt, channel); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (t sqlStorage) SaveDelivery(d *Delivery) error {
+	upsertRow := `INSERT INTO reminder_deliveries (task_id, remind_at, channel, sent_at, attempts, error) VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT (task_id, remind_at, channel) DO UPDATE
+	SET sent_at = excluded.sent_at, attempts = excluded.attempts, error = excluded.error`
+	_, err := t.conn().Exec(t.Db.Rebind(upsertRow), d.TaskId, d.RemindAt, d.Channel, d.SentAt, d.Attempts, d.Error)
+	return err
+}
+
+func (t sqlStorage) PurgeDeliveries(before string) (int, error) {
+	res, err := t.conn().Exec(t.Db.Rebind(`DELETE FROM reminder_deliveries WHERE remind_at < ?`), before)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := res.RowsAffected()
+	return int(purged), err
+}
+
+// RunDeliveriesPurge deletes the delivery state of the reminders which can't be sent anymore every interval.
+// It blocks, so should be started in a separate goroutine.
+func RunDeliveriesPurge(repo ReminderRepository, interval time.Duration) {
+	//the reminder is sent until the end of the day of its task, which is at most MaxReminderOffset
+	//and a day later, the other day is left for the difference of the time zones
+	retention := task.MaxReminderOffset + 48*time.Hour
+	runPeriodically(interval, "reminder deliveries", func() (int, error) {
+		return repo.PurgeDeliveries(time.Now().Add(-retention).UTC().Format(time.RFC3339))
+	})
+}
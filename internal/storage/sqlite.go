@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	gosqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is sqlite3 with UPPER working for all letters, the built-in one converts ASCII only
+const sqliteDriver = "sqlite3_unicode"
+
+func init() {
+	sql.Register(sqliteDriver, &gosqlite3.SQLiteDriver{
+		ConnectHook: func(conn *gosqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("upper", unicodeUpper, true)
+		},
+	})
+}
+
+func unicodeUpper(value any) any {
+	if s, ok := value.(string); ok {
+		return strings.ToUpper(s)
+	}
+	return value
+}
+
+type SQLiteStorage struct {
+	sqlStorage
+}
+
+// MigrateUp applies migrations and enables the full text search, if its migration has taken effect
+// on the SQLite build with FTS5. The index is rebuilt, since the builds without FTS5 don't update it.
+func (s *SQLiteStorage) MigrateUp(steps int) error {
+	if err := MigrateUp(s.Db, steps); err != nil {
+		return err
+	}
+	if err := s.Db.Get(&s.fts, ftsAvailable); err != nil || !s.fts {
+		return err
+	}
+	_, err := s.Db.Exec(`INSERT INTO scheduler_fts (scheduler_fts) VALUES ('rebuild')`)
+	return err
+}
+
+func InitDB(dbPath string) (*sqlx.DB, error) {
+	var dbFilePath string
+	if len(dbPath) > 0 {
+		dbFilePath = dbPath
+	} else {
+		appPath, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		dbFilePath = filepath.Join(appPath, "scheduler.Db")
+		log.Printf("Db path that will be used is %s\n", dbFilePath)
+	}
+
+	_, err := os.Stat(dbFilePath)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("Attempt to create new Db file by path: %s\n", dbFilePath)
+
+			err = os.MkdirAll(filepath.Dir(dbFilePath), 0766)
+			if err != nil {
+				return nil, err
+			}
+			f, err := os.Create(dbFilePath)
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+
+			log.Println("New Db file successfully created")
+		} else {
+			return nil, err
+		}
+	}
+	log.Printf("Connecting to Db by path: %s\n", dbFilePath)
+	conn, err := sql.Open(sqliteDriver, dbFilePath)
+	if err != nil {
+		return nil, err
+	}
+	//the plain driver name is kept for migrations and placeholders
+	Db := sqlx.NewDb(conn, sqlite3)
+	if err := Db.Ping(); err != nil {
+		Db.Close()
+		return nil, err
+	}
+
+	return Db, nil
+}
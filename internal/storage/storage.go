@@ -1,168 +1,376 @@
 package storage
 
 import (
-	"log"
-	"os"
-	"path/filepath"
+	"errors"
+	"fmt"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/OlegShamkeev/go_final_project/internal/config"
 	"github.com/OlegShamkeev/go_final_project/internal/task"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/mattn/go-sqlite3"
 )
 
 var cfg *config.Config
 
-type Storage struct {
-	Db *sqlx.DB
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
+)
+
+const taskColumns = `id, date, title, comment, repeat, deleted_at, user_id, project_id, priority, time_of_day, timezone, reminders, version,
+	EXISTS (` + openBlockers + `) AS blocked`
+
+// ErrVersionConflict is returned by the update of the task with the outdated version
+var ErrVersionConflict = errors.New("task has been changed since it was read")
+
+// memberProjects limits the tasks and the history to the projects the user is a member of
+const memberProjects = `project_id IN (SELECT project_id FROM project_members WHERE user_id = ?)`
+
+type TaskRepository interface {
+	CreateTask(task *task.Task) (int, error)
+	GetTasks(query TaskQuery) (*TaskPage, error)
+	GetAllTasks() ([]task.Task, error)
+	GetTask(id int) (*task.Task, error)
+	UpdateTask(task *task.Task) error
+	DeleteTask(id int) error
+}
+
+// Repository joins all repositories provided by the storage
+type Repository interface {
+	TaskRepository
+	CompletionRepository
+	TrashRepository
+	UserRepository
+	TokenRepository
+	ApiKeyRepository
+	ProjectRepository
+	TagRepository
+	ItemRepository
+	DependencyRepository
+	ReminderRepository
+	AuditRepository
+	// ForUser returns repository which reads and changes only the tasks and the history of the projects
+	// the user is a member of. Repository returned by New isn't bound to any user, so it sees no tasks.
+	// Roles of the user in the projects aren't checked by the repository.
+	ForUser(userId int) Repository
+	// InTx runs fn with repository bound to a transaction, which is committed if fn returns nil.
+	// Calls of InTx inside of fn work as savepoints of the outer transaction: the changes of the failed
+	// call are rolled back and the outer transaction goes on.
+	InTx(fn func(repo Repository) error) error
+	Close() error
+}
+
+// Migrator is implemented by the repositories which keep their schema in the database.
+type Migrator interface {
+	MigrateUp(steps int) error
+	MigrateDown(steps int) error
+	Migrations() ([]MigrationStatus, error)
 }
 
 func NewStorage(config *config.Config) {
 	cfg = config
 }
 
-func InitDB(dbPath string) (*sqlx.DB, error) {
-	var dbFilePath string
-	if len(dbPath) > 0 {
-		dbFilePath = dbPath
-	} else {
-		appPath, err := os.Getwd()
+// New returns repository for the driver configured in config.Config.
+// Postgres is used when driver isn't set explicitly but DSN is, otherwise SQLite is the default.
+func New(config *config.Config) (Repository, error) {
+	driver := config.DBDriver
+	if len(driver) == 0 {
+		driver = DriverSQLite
+		if len(config.PostgresDSN) > 0 {
+			driver = DriverPostgres
+		}
+	}
+
+	switch driver {
+	case DriverSQLite:
+		Db, err := InitDB(config.DBPath)
 		if err != nil {
 			return nil, err
 		}
-		dbFilePath = filepath.Join(appPath, "scheduler.Db")
-		log.Printf("Db path that will be used is %s\n", dbFilePath)
+		return &SQLiteStorage{sqlStorage{Db: Db}}, nil
+	case DriverPostgres:
+		Db, err := InitPostgres(config.PostgresDSN)
+		if err != nil {
+			return nil, err
+		}
+		return &PostgresStorage{sqlStorage{Db: Db}}, nil
+	case DriverMemory:
+		return NewMemoryStorage(), nil
+	default:
+		return nil, fmt.Errorf("unknown db driver %q, expected %s, %s or %s",
+			driver, DriverSQLite, DriverPostgres, DriverMemory)
 	}
+}
 
-	var install bool
-
-	_, err := os.Stat(dbFilePath)
+// sqlStorage holds queries shared by SQL backends, placeholders are rebound for the concrete driver.
+type sqlStorage struct {
+	Db *sqlx.DB
+	tx *sqlx.Tx
+	// fts is set when the full text index of the tasks is available, SQLite only
+	fts bool
+	// userId scopes the queries of tasks and completions by the membership in projects
+	userId int
+}
 
-	if err != nil {
-		if os.IsNotExist(err) {
-			log.Printf("Attempt to create new Db file by path: %s\n", dbFilePath)
+// conn returns the transaction if the storage is bound to it, otherwise the database
+func (t sqlStorage) conn() sqlx.Ext {
+	if t.tx != nil {
+		return t.tx
+	}
+	return t.Db
+}
 
-			install = true
+func (t sqlStorage) ForUser(userId int) Repository {
+	scoped := t
+	scoped.userId = userId
+	return scoped
+}
 
-			err = os.MkdirAll(filepath.Dir(dbFilePath), 0766)
-			if err != nil {
-				return nil, err
-			}
-			f, err := os.Create(dbFilePath)
-			if err != nil {
-				return nil, err
-			}
-			defer f.Close()
+func (t sqlStorage) InTx(fn func(repo Repository) error) error {
+	if t.tx != nil {
+		return t.savepoint(func() error {
+			return fn(t)
+		})
+	}
+	return t.withTx(func(bound sqlStorage) error {
+		return fn(bound)
+	})
+}
 
-			log.Println("New Db file successfully created")
-		} else {
-			return nil, err
-		}
+// savepoint runs fn in the savepoint of the transaction the storage is bound to, the changes made by fn
+// are rolled back if it fails. The savepoints with the same name are nested, the latest one is used.
+func (t sqlStorage) savepoint(fn func() error) error {
+	if _, err := t.tx.Exec(`SAVEPOINT nested`); err != nil {
+		return err
 	}
-	log.Printf("Connecting to Db by path: %s\n", dbFilePath)
-	Db, err := sqlx.Connect("sqlite3", dbFilePath)
-	if err != nil {
-		return nil, err
+	if err := fn(); err != nil {
+		//the savepoint stays after the rollback to it, so it's released as well
+		if _, rollbackErr := t.tx.Exec(`ROLLBACK TO SAVEPOINT nested`); rollbackErr != nil {
+			return rollbackErr
+		}
+		if _, releaseErr := t.tx.Exec(`RELEASE SAVEPOINT nested`); releaseErr != nil {
+			return releaseErr
+		}
+		return err
 	}
+	_, err := t.tx.Exec(`RELEASE SAVEPOINT nested`)
+	return err
+}
 
-	if install {
-		if err = createTableAndIndex(Db); err != nil {
-			return nil, err
-		}
+// withTx runs fn with the storage bound to the transaction, the outer transaction is joined if there is one
+func (t sqlStorage) withTx(fn func(bound sqlStorage) error) error {
+	if t.tx != nil {
+		return fn(t)
 	}
+	return inTx(t.Db, func(tx *sqlx.Tx) error {
+		bound := t
+		bound.tx = tx
+		return fn(bound)
+	})
+}
 
-	return Db, nil
+func (t sqlStorage) Close() error {
+	return t.Db.Close()
 }
 
-func createTableAndIndex(Db *sqlx.DB) error {
-	log.Println("Creating new table scheduler with index")
-	schema := `CREATE TABLE scheduler (id INTEGER PRIMARY KEY AUTOINCREMENT, date CHAR(8) NOT NULL DEFAULT "", 
-	title VARCHAR(256) NOT NULL DEFAULT "", comment TEXT NOT NULL DEFAULT "", repeat VARCHAR(128) NOT NULL DEFAULT "")`
+func (t sqlStorage) MigrateUp(steps int) error {
+	return MigrateUp(t.Db, steps)
+}
 
-	_, err := Db.Exec(schema)
-	if err != nil {
-		return err
-	}
-	index := `CREATE INDEX scheduler_date ON scheduler (date)`
-	_, err = Db.Exec(index)
-	if err != nil {
-		return err
-	}
-	return nil
+func (t sqlStorage) MigrateDown(steps int) error {
+	return MigrateDown(t.Db, steps)
 }
 
-func (t Storage) CreateTask(task *task.Task) (int, error) {
-	insertRow := `INSERT INTO scheduler (date, title, comment, repeat) 
-	VALUES (?, ?, ?, ?)`
-	res, err := t.Db.Exec(insertRow, task.Date, task.Title, task.Comment, task.Repeat)
-	if err != nil {
-		return 0, err
+func (t sqlStorage) Migrations() ([]MigrationStatus, error) {
+	return Migrations(t.Db)
+}
+
+func (t sqlStorage) CreateTask(task *task.Task) (int, error) {
+	projectId := task.ProjectId
+	if len(projectId) == 0 {
+		p, err := t.GetDefaultProject()
+		if err != nil {
+			return 0, err
+		}
+		projectId = strconv.Itoa(p.Id)
 	}
-	id, err := res.LastInsertId()
+	insertRow := `INSERT INTO scheduler (date, title, comment, repeat, user_id, project_id, priority, time_of_day, timezone, reminders)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`
+	var id int
+	err := t.withTx(func(bound sqlStorage) error {
+		err := sqlx.Get(bound.conn(), &id, t.Db.Rebind(insertRow),
+			task.Date, task.Title, task.Comment, task.Repeat, t.userId, projectId, priorityOrDefault(task), task.Time, task.TimeZone, task.Reminders)
+		if err != nil {
+			return err
+		}
+		if err := bound.indexTasks(`id = ?`, id); err != nil {
+			return err
+		}
+		return bound.setTags(id, task.Tags)
+	})
 	if err != nil {
 		return 0, err
 	}
 
-	return int(id), nil
+	return id, nil
 }
 
-func (t Storage) GetTasks(search string) ([]task.Task, error) {
-	var selectRows string
-	tasks := []task.Task{}
-	var errM error
+// priorityOrDefault returns the priority of the task, the default one is given to the task without it
+func priorityOrDefault(t *task.Task) string {
+	if len(t.Priority) == 0 {
+		return strconv.Itoa(task.DefaultPriority)
+	}
+	return t.Priority
+}
+
+func (t sqlStorage) GetTasks(query TaskQuery) (*TaskPage, error) {
+	query.userId = t.userId
+	if query.Overdue {
+		selectZones := `SELECT DISTINCT timezone FROM scheduler WHERE ` + memberProjects + ` AND timezone <> ''`
+		if err := sqlx.Select(t.conn(), &query.zones, t.Db.Rebind(selectZones), t.userId); err != nil {
+			return nil, err
+		}
+	}
+	if text, ok := query.text(); ok && t.fts {
+		return t.searchTasks(query, text)
+	}
+	conds, args := query.conditions()
 
-	switch length := len(search); {
-	case length > 0:
-		date, err := time.Parse("02.01.2006", search)
+	page := &TaskPage{Tasks: []task.Task{}}
+	countRows := `SELECT COUNT(*) FROM scheduler WHERE ` + strings.Join(conds, " AND ")
+	if err := sqlx.Get(t.conn(), &page.Total, t.Db.Rebind(countRows), args...); err != nil {
+		return nil, err
+	}
+
+	if len(query.Cursor) > 0 {
+		c, err := decodeCursor(query.Cursor)
+		if err == nil {
+			err = c.split(query)
+		}
 		if err != nil {
-			selectRows = `SELECT * FROM scheduler WHERE UPPER(title) LIKE ? OR UPPER(comment) LIKE ? ORDER BY date LIMIT ?`
-			errM = t.Db.Select(&tasks, selectRows,
-				"%"+strings.ToUpper(search)+"%",
-				"%"+strings.ToUpper(search)+"%",
-				cfg.Limit)
-			break
+			return nil, err
 		}
-		selectRows = `SELECT * FROM scheduler WHERE date = ? LIMIT ?`
-		errM = t.Db.Select(&tasks, selectRows, date.Format("20060102"), cfg.Limit)
+		cond, condArgs := query.afterCursor(c)
+		conds = append(conds, cond)
+		args = append(args, condArgs...)
+	}
 
-	case length == 0:
-		selectRows = `SELECT * FROM scheduler ORDER BY date LIMIT ?`
-		errM = t.Db.Select(&tasks, selectRows, cfg.Limit)
+	limit := query.limit()
+	selectRows := `SELECT ` + taskColumns + ` FROM scheduler WHERE ` + strings.Join(conds, " AND ") +
+		` ORDER BY ` + query.orderBy() + ` LIMIT ?`
+	//one more task is selected to know if there is the next page
+	args = append(args, limit+1)
+	if err := sqlx.Select(t.conn(), &page.Tasks, t.Db.Rebind(selectRows), args...); err != nil {
+		return nil, err
 	}
 
-	if errM != nil {
-		return nil, errM
+	if len(page.Tasks) > limit {
+		page.Tasks = page.Tasks[:limit]
+		last := page.Tasks[limit-1]
+		page.NextCursor = encodeCursor(query.sortKey(last), last.Id)
+	}
+	if err := t.loadTags(page.Tasks); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (t sqlStorage) GetAllTasks() ([]task.Task, error) {
+	tasks := []task.Task{}
+	selectRows := `SELECT ` + taskColumns + ` FROM scheduler WHERE ` + memberProjects + ` AND deleted_at = '' ORDER BY date, time_of_day, id`
+	if err := sqlx.Select(t.conn(), &tasks, t.Db.Rebind(selectRows), t.userId); err != nil {
+		return nil, err
+	}
+	if err := t.loadTags(tasks); err != nil {
+		return nil, err
 	}
 	return tasks, nil
 }
 
-func (t Storage) GetTask(id int) (*task.Task, error) {
-	task := &task.Task{}
-	selectRow := `SELECT * FROM scheduler WHERE id = ?`
-	err := t.Db.Get(task, selectRow, id)
+func (t sqlStorage) GetTask(id int) (*task.Task, error) {
+	tasks := make([]task.Task, 1)
+	selectRow := `SELECT ` + taskColumns + ` FROM scheduler WHERE id = ? AND ` + memberProjects + ` AND deleted_at = ''`
+	err := sqlx.Get(t.conn(), &tasks[0], t.Db.Rebind(selectRow), id, t.userId)
 	if err != nil {
 		return nil, err
 	}
-	return task, nil
+	if err := t.loadTags(tasks); err != nil {
+		return nil, err
+	}
+	if err := t.loadItems(&tasks[0]); err != nil {
+		return nil, err
+	}
+	return &tasks[0], nil
 }
 
-func (t Storage) UpdateTask(task *task.Task) error {
-	updateRow := `UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ? WHERE id = ?`
-	_, err := t.Db.Exec(updateRow, task.Date, task.Title, task.Comment, task.Repeat, task.Id)
-	if err != nil {
-		return err
+func (t sqlStorage) UpdateTask(task *task.Task) error {
+	updateRow := `UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ?, time_of_day = ?, timezone = ?, reminders = ?`
+	args := []any{task.Date, task.Title, task.Comment, task.Repeat, task.Time, task.TimeZone, task.Reminders}
+	//the task is moved to the other project only if the project is set
+	if len(task.ProjectId) > 0 {
+		updateRow += `, project_id = ?`
+		args = append(args, task.ProjectId)
+	}
+	if len(task.Priority) > 0 {
+		updateRow += `, priority = ?`
+		args = append(args, task.Priority)
 	}
-	return nil
+	found := `id = ? AND ` + memberProjects + ` AND deleted_at = ''`
+	foundArgs := []any{task.Id, t.userId}
+	cond, condArgs := found, foundArgs
+	if task.Version > 0 {
+		cond += ` AND version = ?`
+		condArgs = []any{task.Id, t.userId, task.Version}
+	}
+	updateRow += `, version = version + 1 WHERE ` + cond
+	args = append(args, condArgs...)
+	return t.withTx(func(bound sqlStorage) error {
+		if err := bound.unindexTasks(cond, condArgs...); err != nil {
+			return err
+		}
+		res, err := bound.conn().Exec(t.Db.Rebind(updateRow), args...)
+		if err != nil {
+			return err
+		}
+		//the task isn't found by the version only if it has been changed since it was read
+		if task.Version > 0 {
+			affected, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if affected == 0 {
+				return ErrVersionConflict
+			}
+		}
+		if err := bound.indexTasks(found, foundArgs...); err != nil {
+			return err
+		}
+		if task.Tags == nil {
+			return nil
+		}
+		//the tags of the task out of reach of the user aren't touched
+		if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+			return err
+		}
+		id, err := strconv.Atoi(task.Id)
+		if err != nil {
+			return err
+		}
+		return bound.setTags(id, task.Tags)
+	})
 }
 
-func (t Storage) DeleteTask(id int) error {
-	deleteRow := `DELETE FROM scheduler where id = ?`
-	_, err := t.Db.Exec(deleteRow, id)
-	if err != nil {
+func (t sqlStorage) DeleteTask(id int) error {
+	return t.withTx(func(bound sqlStorage) error {
+		if err := bound.deleteTaskRows(`id = ? AND `+memberProjects, id, t.userId); err != nil {
+			return err
+		}
+		deleteRow := `DELETE FROM scheduler where id = ? AND ` + memberProjects
+		_, err := bound.conn().Exec(t.Db.Rebind(deleteRow), id, t.userId)
 		return err
-	}
-	return nil
+	})
 }
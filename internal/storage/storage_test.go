@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/config"
+	"github.com/OlegShamkeev/go_final_project/internal/project"
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errRollback = errors.New("rollback")
+
+// forEachStorage runs the test against SQLite and memory storages, the repository is bound to the default user
+func forEachStorage(t *testing.T, test func(t *testing.T, repo Repository)) {
+	NewStorage(&config.Config{Limit: 50})
+	for _, driver := range []string{DriverSQLite, DriverMemory} {
+		t.Run(driver, func(t *testing.T) {
+			repo, err := New(&config.Config{DBDriver: driver, DBPath: filepath.Join(t.TempDir(), "scheduler.db")})
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer repo.Close()
+			if migrator, ok := repo.(Migrator); ok {
+				if !assert.NoError(t, migrator.MigrateUp(0)) {
+					return
+				}
+			}
+			test(t, repo.ForUser(user.DefaultId))
+		})
+	}
+}
+
+func addTask(t *testing.T, repo Repository, title string) int {
+	id, err := repo.CreateTask(&task.Task{Date: "20261018", Title: title})
+	assert.NoError(t, err)
+	return id
+}
+
+func TestTasks(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, repo Repository) {
+		id := addTask(t, repo, "Купить молоко")
+		stored, err := repo.GetTask(id)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "Купить молоко", stored.Title)
+
+		stored.Title = "Купить кефир"
+		assert.NoError(t, repo.UpdateTask(stored))
+		updated, err := repo.GetTask(id)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "Купить кефир", updated.Title)
+		assert.Greater(t, updated.Version, stored.Version)
+
+		//the version read before the update is outdated
+		stored.Title = "Купить хлеб"
+		assert.ErrorIs(t, repo.UpdateTask(stored), ErrVersionConflict)
+
+		assert.NoError(t, repo.DeleteTask(id))
+		_, err = repo.GetTask(id)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+func TestForUser(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, repo Repository) {
+		id := addTask(t, repo, "Позвонить маме")
+
+		userId, err := repo.CreateUser(&user.User{Login: "storage_test", Role: user.RoleEditor})
+		if !assert.NoError(t, err) {
+			return
+		}
+		other := repo.ForUser(userId)
+		_, err = other.CreateProject(&project.Project{Name: project.DefaultName, IsDefault: true})
+		assert.NoError(t, err)
+
+		_, err = other.GetTask(id)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+		assert.NoError(t, other.DeleteTask(id))
+		_, err = repo.GetTask(id)
+		assert.NoError(t, err)
+	})
+}
+
+func TestInTx(t *testing.T) {
+	forEachStorage(t, func(t *testing.T, repo Repository) {
+		var rolledBack, kept, failed int
+		err := repo.InTx(func(repo Repository) error {
+			rolledBack = addTask(t, repo, "Отменённая задача")
+			return errRollback
+		})
+		assert.ErrorIs(t, err, errRollback)
+		_, err = repo.GetTask(rolledBack)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		//the failed nested call is rolled back like the savepoint, the outer transaction is committed
+		err = repo.InTx(func(repo Repository) error {
+			kept = addTask(t, repo, "Сохранённая задача")
+			err := repo.InTx(func(repo Repository) error {
+				failed = addTask(t, repo, "Задача отменённой точки сохранения")
+				return errRollback
+			})
+			assert.ErrorIs(t, err, errRollback)
+			return nil
+		})
+		assert.NoError(t, err)
+		_, err = repo.GetTask(kept)
+		assert.NoError(t, err)
+		_, err = repo.GetTask(failed)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+func TestMemoryInTxConcurrentWrite(t *testing.T) {
+	NewStorage(&config.Config{Limit: 50})
+	repo := NewMemoryStorage().ForUser(user.DefaultId)
+
+	outside := make(chan int)
+	var inside int
+	err := repo.InTx(func(tx Repository) error {
+		inside = addTask(t, tx, "Задача транзакции")
+		go func() {
+			outside <- addTask(t, repo, "Задача вне транзакции")
+		}()
+		//the change outside of the transaction is made while it runs
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	assert.NoError(t, err)
+	outsideId := <-outside
+
+	tasks, err := repo.GetAllTasks()
+	assert.NoError(t, err)
+	ids := []string{}
+	for _, t := range tasks {
+		ids = append(ids, t.Id)
+	}
+	assert.ElementsMatch(t, []string{strconv.Itoa(inside), strconv.Itoa(outsideId)}, ids)
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TagCount is the tag with the number of the tasks marked by it
+type TagCount struct {
+	Name  string `json:"name" db:"name"`
+	Count int    `json:"count" db:"count"`
+}
+
+// TagRepository returns the tags used by the tasks of the user, the tags of the tasks are kept by TaskRepository
+type TagRepository interface {
+	// GetTags returns the tags with the number of the tasks not in the trash, the most used tags go first
+	GetTags() ([]TagCount, error)
+}
+
+func (t sqlStorage) GetTags() ([]TagCount, error) {
+	tags := []TagCount{}
+	selectRows := `SELECT g.name, COUNT(*) AS count FROM tags g
+	JOIN task_tags tt ON tt.tag_id = g.id
+	JOIN scheduler s ON s.id = tt.task_id
+	WHERE s.` + memberProjects + ` AND s.deleted_at = ''
+	GROUP BY g.name ORDER BY count DESC, g.name`
+	if err := sqlx.Select(t.conn(), &tags, t.Db.Rebind(selectRows), t.userId); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// setTags replaces the tags of the task, the tags are created on the first use
+func (t sqlStorage) setTags(taskId int, tags []string) error {
+	if _, err := t.conn().Exec(t.Db.Rebind(`DELETE FROM task_tags WHERE task_id = ?`), taskId); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		insertTag := `INSERT INTO tags (name) VALUES (?) ON CONFLICT (name) DO NOTHING`
+		if _, err := t.conn().Exec(t.Db.Rebind(insertTag), tag); err != nil {
+			return err
+		}
+		insertRow := `INSERT INTO task_tags (task_id, tag_id) SELECT ?, id FROM tags WHERE name = ?`
+		if _, err := t.conn().Exec(t.Db.Rebind(insertRow), taskId, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadTags fills the tags of the tasks by one query
+func (t sqlStorage) loadTags(tasks []task.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.Id)
+	}
+	selectRows, args, err := sqlx.In(`SELECT tt.task_id, g.name FROM task_tags tt JOIN tags g ON g.id = tt.tag_id
+	WHERE tt.task_id IN (?) ORDER BY g.name`, ids)
+	if err != nil {
+		return err
+	}
+	rows := []struct {
+		TaskId int    `db:"task_id"`
+		Name   string `db:"name"`
+	}{}
+	if err := sqlx.Select(t.conn(), &rows, t.Db.Rebind(selectRows), args...); err != nil {
+		return err
+	}
+	tags := make(map[string][]string, len(tasks))
+	for _, row := range rows {
+		id := strconv.Itoa(row.TaskId)
+		tags[id] = append(tags[id], row.Name)
+	}
+	for i := range tasks {
+		tasks[i].Tags = tags[tasks[i].Id]
+	}
+	return nil
+}
+
+// deleteTaskRows deletes the tags, the checklists, the dependencies and the index entries of the tasks selected
+// by the condition on the scheduler table
+func (t sqlStorage) deleteTaskRows(cond string, args ...any) error {
+	if err := t.unindexTasks(cond, args...); err != nil {
+		return err
+	}
+	for _, column := range []string{"task_tags.task_id", "task_items.task_id",
+		"task_dependencies.task_id", "task_dependencies.blocker_id"} {
+		table, key, _ := strings.Cut(column, ".")
+		deleteRows := `DELETE FROM ` + table + ` WHERE ` + key + ` IN (SELECT id FROM scheduler WHERE ` + cond + `)`
+		if _, err := t.conn().Exec(t.Db.Rebind(deleteRows), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
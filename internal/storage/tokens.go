@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TokenRepository keeps the denylist of the revoked tokens until they expire, it isn't scoped by ForUser.
+// Timestamps are in the time.RFC3339 format of UTC.
+type TokenRepository interface {
+	// RevokeToken adds the token to the denylist and reports if it wasn't there, only one of the concurrent calls
+	// for the token gets true
+	RevokeToken(jti string, userId int, expiresAt string) (bool, error)
+	IsTokenRevoked(jti string) (bool, error)
+	// PurgeRevokedTokens deletes the tokens expired before the timestamp, they are rejected anyway
+	PurgeRevokedTokens(before string) (int, error)
+}
+
+func (t sqlStorage) RevokeToken(jti string, userId int, expiresAt string) (bool, error) {
+	insertRow := `INSERT INTO revoked_tokens (jti, user_id, expires_at) VALUES (?, ?, ?) ON CONFLICT (jti) DO NOTHING`
+	res, err := t.conn().Exec(t.Db.Rebind(insertRow), jti, userId, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted > 0, nil
+}
+
+func (t sqlStorage) IsTokenRevoked(jti string) (bool, error) {
+	var found string
+	selectRow := `SELECT jti FROM revoked_tokens WHERE jti = ?`
+	err := sqlx.Get(t.conn(), &found, t.Db.Rebind(selectRow), jti)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (t sqlStorage) PurgeRevokedTokens(before string) (int, error) {
+	deleteRows := `DELETE FROM revoked_tokens WHERE expires_at < ?`
+	res, err := t.conn().Exec(t.Db.Rebind(deleteRows), before)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(purged), nil
+}
+
+// RunRevokedTokensPurge deletes expired tokens from the denylist every interval.
+// It blocks, so should be started in a separate goroutine.
+func RunRevokedTokensPurge(repo TokenRepository, interval time.Duration) {
+	runPeriodically(interval, "revoked token(s)", func() (int, error) {
+		return repo.PurgeRevokedTokens(time.Now().UTC().Format(time.RFC3339))
+	})
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/task"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TrashRepository moves tasks to the trash instead of deleting them, tasks in the trash
+// aren't returned by TaskRepository. Timestamps are in the time.RFC3339 format of UTC.
+// PurgeTrash isn't scoped by ForUser, it deletes the tasks of all users.
+type TrashRepository interface {
+	TrashTask(id int, deletedAt string) error
+	RestoreTask(id int) error
+	GetTrash() ([]task.Task, error)
+	// PurgeTrash permanently deletes tasks moved to the trash before the timestamp
+	PurgeTrash(before string) (int, error)
+}
+
+func (t sqlStorage) TrashTask(id int, deletedAt string) error {
+	updateRow := `UPDATE scheduler SET deleted_at = ? WHERE id = ? AND ` + memberProjects + ` AND deleted_at = ''`
+	res, err := t.conn().Exec(t.Db.Rebind(updateRow), deletedAt, id, t.userId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (t sqlStorage) RestoreTask(id int) error {
+	updateRow := `UPDATE scheduler SET deleted_at = '' WHERE id = ? AND ` + memberProjects + ` AND deleted_at <> ''`
+	res, err := t.conn().Exec(t.Db.Rebind(updateRow), id, t.userId)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (t sqlStorage) GetTrash() ([]task.Task, error) {
+	tasks := []task.Task{}
+	selectRows := `SELECT ` + taskColumns + ` FROM scheduler WHERE ` + memberProjects + ` AND deleted_at <> ''
+	ORDER BY deleted_at DESC, id DESC`
+	if err := sqlx.Select(t.conn(), &tasks, t.Db.Rebind(selectRows), t.userId); err != nil {
+		return nil, err
+	}
+	if err := t.loadTags(tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (t sqlStorage) PurgeTrash(before string) (int, error) {
+	var purged int64
+	err := t.withTx(func(bound sqlStorage) error {
+		if err := bound.deleteTaskRows(`deleted_at <> '' AND deleted_at < ?`, before); err != nil {
+			return err
+		}
+		deleteRows := `DELETE FROM scheduler WHERE deleted_at <> '' AND deleted_at < ?`
+		res, err := bound.conn().Exec(t.Db.Rebind(deleteRows), before)
+		if err != nil {
+			return err
+		}
+		purged, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(purged), nil
+}
+
+// checkAffected returns sql.ErrNoRows if the statement hasn't changed any row
+func checkAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RunTrashPurge permanently deletes tasks kept in the trash longer than retention every interval.
+// It blocks, so should be started in a separate goroutine.
+func RunTrashPurge(repo TrashRepository, retention time.Duration, interval time.Duration) {
+	runPeriodically(interval, "task(s) from the trash", func() (int, error) {
+		return repo.PurgeTrash(time.Now().Add(-retention).UTC().Format(time.RFC3339))
+	})
+}
+
+// runPeriodically calls purge right away and then every interval, what is the name of purged items for the log
+func runPeriodically(interval time.Duration, what string, purge func() (int, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		purged, err := purge()
+		if err != nil {
+			log.Printf("Error during purge of %s: %s\n", what, err.Error())
+		} else if purged > 0 {
+			log.Printf("Purged %d %s\n", purged, what)
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/OlegShamkeev/go_final_project/internal/user"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const userColumns = `id, login, password_hash, role, created_at, timezone`
+
+// UserRepository keeps accounts, users aren't scoped by ForUser
+type UserRepository interface {
+	CreateUser(user *user.User) (int, error)
+	GetUser(id int) (*user.User, error)
+	GetUserByLogin(login string) (*user.User, error)
+	UpdateUserPassword(id int, passwordHash string) error
+	GetUsers() ([]user.User, error)
+	UpdateUserRole(id int, role string) error
+	UpdateUserTimeZone(id int, timeZone string) error
+}
+
+func (t sqlStorage) CreateUser(user *user.User) (int, error) {
+	insertRow := `INSERT INTO users (login, password_hash, role, created_at) VALUES (?, ?, ?, ?) RETURNING id`
+	var id int
+	err := sqlx.Get(t.conn(), &id, t.Db.Rebind(insertRow),
+		user.Login, user.PasswordHash, user.Role, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (t sqlStorage) GetUser(id int) (*user.User, error) {
+	u := &user.User{}
+	selectRow := `SELECT ` + userColumns + ` FROM users WHERE id = ?`
+	if err := sqlx.Get(t.conn(), u, t.Db.Rebind(selectRow), id); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (t sqlStorage) GetUserByLogin(login string) (*user.User, error) {
+	u := &user.User{}
+	selectRow := `SELECT ` + userColumns + ` FROM users WHERE login = ?`
+	if err := sqlx.Get(t.conn(), u, t.Db.Rebind(selectRow), login); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (t sqlStorage) UpdateUserPassword(id int, passwordHash string) error {
+	updateRow := `UPDATE users SET password_hash = ? WHERE id = ?`
+	res, err := t.conn().Exec(t.Db.Rebind(updateRow), passwordHash, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (t sqlStorage) GetUsers() ([]user.User, error) {
+	users := []user.User{}
+	selectRows := `SELECT ` + userColumns + ` FROM users ORDER BY id`
+	if err := sqlx.Select(t.conn(), &users, selectRows); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (t sqlStorage) UpdateUserRole(id int, role string) error {
+	updateRow := `UPDATE users SET role = ? WHERE id = ?`
+	res, err := t.conn().Exec(t.Db.Rebind(updateRow), role, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
+
+func (t sqlStorage) UpdateUserTimeZone(id int, timeZone string) error {
+	updateRow := `UPDATE users SET timezone = ? WHERE id = ?`
+	res, err := t.conn().Exec(t.Db.Rebind(updateRow), timeZone, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res)
+}
@@ -0,0 +1,26 @@
+package task
+
+import "time"
+
+// Completion is a record about the done task, title is saved as it was at the moment of completion
+type Completion struct {
+	Id          string `json:"id,omitempty" db:"id"`
+	TaskId      string `json:"task_id" db:"task_id"`
+	Title       string `json:"title" db:"title"`
+	Date        string `json:"date" db:"date"`
+	CompletedAt string `json:"completed_at" db:"completed_at"`
+	UserId      int    `json:"-" db:"user_id"`
+	ProjectId   string `json:"project_id,omitempty" db:"project_id"`
+}
+
+// Completion makes the record about completion of the task at the scheduled date
+func (task *Task) Completion(completedAt time.Time) *Completion {
+	return &Completion{
+		TaskId:      task.Id,
+		Title:       task.Title,
+		Date:        task.Date,
+		CompletedAt: completedAt.UTC().Format(time.RFC3339),
+		UserId:      task.UserId,
+		ProjectId:   task.ProjectId,
+	}
+}
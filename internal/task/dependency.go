@@ -0,0 +1,8 @@
+package task
+
+// Dependency means the task can't be done until its blocker is done. The blocker is open while
+// it isn't in the trash, the repeating blocker is open until it moves past the date of the task.
+type Dependency struct {
+	TaskId    int `json:"task_id" db:"task_id"`
+	BlockerId int `json:"blocker_id" db:"blocker_id"`
+}
@@ -0,0 +1,29 @@
+package task
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxItems limits the checklist of the task
+const MaxItems = 100
+
+const maxItemLength = 256
+
+// Item is the entry of the checklist of the task with its own done state.
+// The checklist of the repeating task is reset when the task moves to the next date.
+type Item struct {
+	Id     int    `json:"id" db:"id"`
+	TaskId int    `json:"task_id" db:"task_id"`
+	Title  string `json:"title" db:"title"`
+	Done   bool   `json:"done" db:"done"`
+}
+
+// Validate checks the title of the item
+func (item *Item) Validate() string {
+	item.Title = strings.TrimSpace(item.Title)
+	if len(item.Title) == 0 || utf8.RuneCountInString(item.Title) > maxItemLength {
+		return "title of the item should be from 1 to 256 characters"
+	}
+	return ""
+}
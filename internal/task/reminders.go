@@ -0,0 +1,110 @@
+package task
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RemindersNone turns off the default reminders for the task
+const RemindersNone = "none"
+
+// MaxReminderOffset bounds how long before the task the reminder could fire
+const MaxReminderOffset = 30 * 24 * time.Hour
+
+const maxReminders = 5
+
+// ReminderOffset is the moment of the reminder relative to the task: Before the due time of the task,
+// or At the time of day on the date of the task if At is set
+type ReminderOffset struct {
+	Before time.Duration
+	At     string
+}
+
+// ParseReminders parses comma separated offsets: 1d, 2h or 30m before the due time, or 09:00 on the day of the task
+func ParseReminders(s string) ([]ReminderOffset, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 || s == RemindersNone {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	if len(parts) > maxReminders {
+		return nil, fmt.Errorf("task could have at most %d reminders", maxReminders)
+	}
+	offsets := make([]ReminderOffset, 0, len(parts))
+	for _, part := range parts {
+		offset, err := parseReminderOffset(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		offsets = append(offsets, offset)
+	}
+	return offsets, nil
+}
+
+func parseReminderOffset(s string) (ReminderOffset, error) {
+	if strings.Contains(s, ":") {
+		at, err := time.Parse(TimeFormat, s)
+		if err != nil {
+			return ReminderOffset{}, fmt.Errorf("incorrect reminder %q, expected time in the 15:04 format", s)
+		}
+		return ReminderOffset{At: at.Format(TimeFormat)}, nil
+	}
+	units := map[string]time.Duration{"m": time.Minute, "h": time.Hour, "d": 24 * time.Hour}
+	unit, ok := units[s[max(len(s)-1, 0):]]
+	n, err := strconv.Atoi(s[:max(len(s)-1, 0)])
+	if !ok || err != nil || n < 0 || time.Duration(n)*unit > MaxReminderOffset {
+		return ReminderOffset{}, fmt.Errorf("incorrect reminder %q, expected offset like 1d, 2h or 30m up to 30 days", s)
+	}
+	return ReminderOffset{Before: time.Duration(n) * unit}, nil
+}
+
+func (o ReminderOffset) String() string {
+	switch {
+	case len(o.At) > 0:
+		return o.At
+	case o.Before%(24*time.Hour) == 0 && o.Before > 0:
+		return strconv.Itoa(int(o.Before/(24*time.Hour))) + "d"
+	case o.Before%time.Hour == 0 && o.Before > 0:
+		return strconv.Itoa(int(o.Before/time.Hour)) + "h"
+	default:
+		return strconv.Itoa(int(o.Before/time.Minute)) + "m"
+	}
+}
+
+// RemindAt returns the moment of the reminder of the task due at due
+func (o ReminderOffset) RemindAt(due time.Time) time.Time {
+	if len(o.At) > 0 {
+		at, _ := time.Parse(TimeFormat, o.At)
+		return time.Date(due.Year(), due.Month(), due.Day(), at.Hour(), at.Minute(), 0, 0, due.Location())
+	}
+	return due.Add(-o.Before)
+}
+
+// DueAt returns the moment the task is due in loc, the task without time is due at dayTime of its date
+func (task *Task) DueAt(loc *time.Location, dayTime string) (time.Time, error) {
+	clock := task.Time
+	if len(clock) == 0 {
+		clock = dayTime
+	}
+	return time.ParseInLocation(dateTimeFormat+" "+TimeFormat, task.Date+" "+clock, loc)
+}
+
+// normalizeReminders checks the reminders and writes them in the canonical form
+func (task *Task) normalizeReminders() string {
+	if strings.TrimSpace(task.Reminders) == RemindersNone {
+		task.Reminders = RemindersNone
+		return ""
+	}
+	offsets, err := ParseReminders(task.Reminders)
+	if err != nil {
+		return err.Error()
+	}
+	parts := make([]string, 0, len(offsets))
+	for _, offset := range offsets {
+		parts = append(parts, offset.String())
+	}
+	task.Reminders = strings.Join(parts, ",")
+	return ""
+}
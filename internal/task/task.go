@@ -1,43 +1,168 @@
 package task
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/OlegShamkeev/go_final_project/internal/nextdate"
 )
 
 const dateTimeFormat = "20060102"
 
+// TimeFormat is the format of the time of day of the task
+const TimeFormat = "15:04"
+
+const (
+	maxTags      = 20
+	maxTagLength = 64
+)
+
+// priorities go from the highest 1 to the lowest 4, which is given to the tasks without the priority
+const (
+	HighestPriority = 1
+	DefaultPriority = 4
+)
+
 type Task struct {
 	Id      string `json:"id,omitempty" db:"id"`
 	Date    string `json:"date,omitempty" db:"date"`
 	Title   string `json:"title" db:"title"`
 	Comment string `json:"comment,omitempty" db:"comment"`
 	Repeat  string `json:"repeat,omitempty" db:"repeat"`
+	// DeletedAt is set for the tasks moved to the trash
+	DeletedAt string `json:"deleted_at,omitempty" db:"deleted_at"`
+	// UserId is the author of the task
+	UserId int `json:"-" db:"user_id"`
+	// ProjectId is the project the task belongs to, the default project of the user if it isn't set
+	ProjectId string `json:"project_id,omitempty" db:"project_id"`
+	// Time is the optional time of day in the TimeFormat, the task without it takes the whole day
+	Time string `json:"time,omitempty" db:"time_of_day"`
+	// TimeZone is the IANA time zone of the date and the time, the zone of the user is used if it isn't set
+	TimeZone string `json:"timezone,omitempty" db:"timezone"`
+	// Priority is from 1 to 4, the empty priority isn't changed by the update
+	Priority string `json:"priority,omitempty" db:"priority"`
+	// Reminders are the comma separated offsets of the reminders, see ParseReminders,
+	// the default reminders are used if it isn't set and RemindersNone turns them off
+	Reminders string `json:"reminders,omitempty" db:"reminders"`
+	// Version grows with every update of the task, it's returned as the ETag of the task.
+	// The update with the version is applied only if the stored task has the same one.
+	Version int `json:"-" db:"version"`
+	// Tags are kept in the separate table, nil tags aren't changed by the update
+	Tags []string `json:"tags,omitempty" db:"-"`
+	// Blocked is set if any blocker of the task is open, see Dependency
+	Blocked bool `json:"blocked,omitempty" db:"blocked"`
+	// Items are the checklist of the task, they are returned only with the single task
+	Items []Item `json:"items,omitempty" db:"-"`
+	// Snippet is the html fragment of the task text matched by the full text search
+	Snippet string `json:"snippet,omitempty" db:"snippet"`
 }
 
-func (task *Task) ValidateAndUpdateTask(update bool) string {
+// ValidateAndUpdateTask checks the task and moves its date to today or to the next date of the repeat rule.
+// Now is the current time in the time zone of the user, the time zone of the task takes precedence.
+func (task *Task) ValidateAndUpdateTask(now time.Time, update bool) string {
 
 	if len(strings.TrimSpace(task.Title)) == 0 {
 		return "field title couldn't be empty"
 	}
+	if resultValidate := task.normalizeTags(); resultValidate != "" {
+		return resultValidate
+	}
+	if priority := strings.TrimSpace(task.Priority); len(priority) > 0 {
+		priorityInt, err := strconv.Atoi(priority)
+		if err != nil || priorityInt < HighestPriority || priorityInt > DefaultPriority {
+			return "priority should be a number from 1 to 4"
+		}
+		task.Priority = strconv.Itoa(priorityInt)
+	}
+	if len(task.Time) > 0 {
+		timeParsed, err := time.Parse(TimeFormat, strings.TrimSpace(task.Time))
+		if err != nil {
+			return "time should be in the 15:04 format"
+		}
+		task.Time = timeParsed.Format(TimeFormat)
+	}
+	if resultValidate := task.normalizeReminders(); resultValidate != "" {
+		return resultValidate
+	}
+	if len(task.TimeZone) > 0 {
+		loc, err := LoadZone(task.TimeZone)
+		if err != nil {
+			return err.Error()
+		}
+		now = now.In(loc)
+	}
 
 	if len(strings.TrimSpace(task.Date)) == 0 {
-		task.Date = time.Now().Format(dateTimeFormat)
+		task.Date = now.Format(dateTimeFormat)
 	} else {
-		dateParsed, err := time.Parse(dateTimeFormat, task.Date)
+		_, err := time.Parse(dateTimeFormat, task.Date)
 		if err != nil {
 			return err.Error()
 		}
 		if len(strings.TrimSpace(task.Repeat)) > 0 {
-			task.Date, err = nextdate.NextDate(time.Now(), task.Date, task.Repeat, update)
+			date, repeat, err := nextdate.NextDateAndRepeat(now, task.Date, task.Repeat, update)
+			//the series of the rule is over, so task turns into an ordinary one
+			if errors.Is(err, nextdate.ErrNoMoreOccurrences) && update {
+				task.Repeat = ""
+				return ""
+			}
 			if err != nil {
 				return err.Error()
 			}
-		} else if dateParsed.Before(time.Now()) {
-			task.Date = time.Now().Format(dateTimeFormat)
+			task.Date, task.Repeat = date, repeat
+		} else if task.Date < now.Format(dateTimeFormat) {
+			task.Date = now.Format(dateTimeFormat)
+		}
+	}
+	return ""
+}
+
+// LoadZone returns the IANA time zone of the task or the user, the empty name is the local zone of the server
+func LoadZone(name string) (*time.Location, error) {
+	if len(name) == 0 {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q, expected IANA name like Europe/Moscow", name)
+	}
+	return loc, nil
+}
+
+// Overdue checks that the date of the task, or the time of the task planned for today, has passed.
+// Now is the current time in the time zone of the user, the time zone of the task takes precedence.
+func (task *Task) Overdue(now time.Time) bool {
+	if loc, err := LoadZone(task.TimeZone); err == nil && len(task.TimeZone) > 0 {
+		now = now.In(loc)
+	}
+	today := now.Format(dateTimeFormat)
+	return task.Date < today || (task.Date == today && len(task.Time) > 0 && task.Time < now.Format(TimeFormat))
+}
+
+// normalizeTags trims and lowercases the tags and drops the duplicates
+func (task *Task) normalizeTags() string {
+	if task.Tags == nil {
+		return ""
+	}
+	tags := make([]string, 0, len(task.Tags))
+	seen := make(map[string]bool, len(task.Tags))
+	for _, tag := range task.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if len(tag) == 0 || utf8.RuneCountInString(tag) > maxTagLength {
+			return "tag should be from 1 to 64 characters"
 		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > maxTags {
+		return "task could have at most 20 tags"
 	}
+	task.Tags = tags
 	return ""
 }
@@ -0,0 +1,92 @@
+package user
+
+import (
+	"regexp"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultId is the user created by migrations, the tasks made before accounts were added belong to it.
+// Its password is TODO_PASSWORD, without the password authentication is disabled and all requests are made by it.
+const (
+	DefaultId    = 1
+	DefaultLogin = "admin"
+)
+
+// Roles from the lowest to the highest, each role is permitted everything the lower ones are
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+var roleLevels = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3}
+
+const minPasswordLength = 6
+
+var loginPattern = regexp.MustCompile(`^[a-zA-Z0-9_.@-]{3,64}$`)
+
+// dummyHash is compared with the password if there is no hash of the user to compare with,
+// so the failed sign in takes the same time whether the login exists or not
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+type User struct {
+	Id           int    `json:"id" db:"id"`
+	Login        string `json:"login" db:"login"`
+	PasswordHash string `json:"-" db:"password_hash"`
+	Role         string `json:"role" db:"role"`
+	CreatedAt    string `json:"created_at" db:"created_at"`
+	// TimeZone is the IANA time zone the today of the tasks is found in, the local zone of the server if it isn't set
+	TimeZone string `json:"timezone" db:"timezone"`
+}
+
+// Validate checks the login and the password of the new user
+func Validate(login string, password string) string {
+	if !loginPattern.MatchString(login) {
+		return "login should be from 3 to 64 latin letters, digits or _.@- characters"
+	}
+	if len(password) < minPasswordLength {
+		return "password should be at least 6 characters long"
+	}
+	return ""
+}
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword compares the password with the hash, users without hash can't sign in
+func (u *User) CheckPassword(password string) bool {
+	if len(u.PasswordHash) == 0 {
+		CheckDummyPassword(password)
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
+}
+
+// CheckDummyPassword takes as long as the check of the password of the user, it's called for the unknown login
+func CheckDummyPassword(password string) {
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// Permits checks whether the role is the required one or higher
+func Permits(role string, required string) bool {
+	return roleLevels[role] >= roleLevels[required]
+}
+
+// LowerRole returns the lower of the roles, the empty role is ignored
+func LowerRole(a string, b string) string {
+	if len(a) == 0 || (len(b) > 0 && roleLevels[b] < roleLevels[a]) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func requestWithKey(key string, apipath string, values map[string]any, method string) (map[string]any, int, error) {
+	var data []byte
+	if len(values) > 0 {
+		var err error
+		if data, err = json.Marshal(values); err != nil {
+			return nil, 0, err
+		}
+	}
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewBuffer(data))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	var m map[string]any
+	err = json.Unmarshal(body, &m)
+	return m, resp.StatusCode, err
+}
+
+func createKey(t *testing.T, name string, scope string) (string, string) {
+	ret, status, err := requestAs(Token, "api/keys", map[string]any{"name": name, "scope": scope}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status, ret["error"])
+	assert.Equal(t, scope, ret["scope"])
+	key, _ := ret["key"].(string)
+	assert.NotEmpty(t, key)
+	return fmt.Sprint(ret["id"]), key
+}
+
+func TestApiKeys(t *testing.T) {
+	if len(os.Getenv("TODO_PASSWORD")) == 0 {
+		t.Skip("Ключи API доступны только с паролем, задайте TODO_PASSWORD серверу и тестам")
+	}
+
+	for _, values := range []map[string]any{
+		{"name": ""},
+		{"name": "ci", "scope": "admin"},
+		{"name": "ci", "expires_at": "20240101"},
+		{"name": "ci", "expires_at": "2000-01-01T00:00:00Z"},
+	} {
+		_, status, err := requestAs(Token, "api/keys", values, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, status, values)
+	}
+
+	readId, readKey := createKey(t, "read only", "read")
+	writeId, writeKey := createKey(t, "ci", "write")
+
+	ret, status, err := requestAs(Token, "api/keys", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	keys, _ := ret["keys"].([]any)
+	found := 0
+	for _, k := range keys {
+		m := k.(map[string]any)
+		assert.Nil(t, m["key"])
+		//the role is granted on creation by the scope
+		switch fmt.Sprint(m["id"]) {
+		case readId:
+			assert.Equal(t, "viewer", m["role"])
+			found++
+		case writeId:
+			assert.Equal(t, "editor", m["role"])
+			found++
+		}
+	}
+	assert.Equal(t, 2, found)
+
+	_, status, err = requestWithKey(readKey, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	_, status, err = requestWithKey(readKey, "api/task", map[string]any{"title": "Ключ чтения"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	ret, status, err = requestWithKey(writeKey, "api/task", map[string]any{"title": "Задача из CI"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status)
+	id := fmt.Sprint(ret["id"])
+
+	//the task belongs to the owner of the key
+	ret, status, err = requestAs(Token, "api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Задача из CI", ret["title"])
+
+	_, status, err = requestWithKey(writeKey, "api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	//keys can't manage keys
+	_, status, err = requestWithKey(writeKey, "api/keys", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	_, status, err = requestWithKey(writeKey+"0", "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	//the access token is accepted in the header as well
+	_, status, err = requestWithKey(Token, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	for _, keyId := range []string{readId, writeId} {
+		_, status, err = requestAs(Token, "api/keys?id="+keyId, nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, status)
+	}
+	_, status, err = requestAs(Token, "api/keys?id="+readId, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	_, status, err = requestWithKey(writeKey, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
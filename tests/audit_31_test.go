@@ -0,0 +1,173 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func auditEntries(t *testing.T, query url.Values) []map[string]any {
+	ret, status, err := requestAs(Token, "api/audit?"+query.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status, ret["error"])
+	entries := []map[string]any{}
+	list, _ := ret["entries"].([]any)
+	for _, entry := range list {
+		entries = append(entries, entry.(map[string]any))
+	}
+	return entries
+}
+
+func TestAudit(t *testing.T) {
+	today := time.Now().Format("20060102")
+	ret, status, err := requestAs(Token, "api/task", map[string]any{"title": "Заполнить анкету", "date": today}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status, ret["error"])
+	id := fmt.Sprint(ret["id"])
+
+	_, status, _ = requestIfMatch(t, "api/task", map[string]any{"id": id, "title": "Заполнить и отправить анкету",
+		"date": today}, http.MethodPut, taskETag(t, id))
+	assert.Equal(t, http.StatusOK, status)
+	_, status, _ = requestIfMatch(t, "api/task", map[string]any{"id": id, "title": "", "date": today}, http.MethodPut, taskETag(t, id))
+	assert.NotEqual(t, http.StatusOK, status)
+	//the operations of the rolled back batch are recorded as well
+	batchResults(t, map[string]any{"operations": []any{
+		map[string]any{"op": "update", "task": map[string]any{"id": id, "title": "Выбросить анкету", "date": today},
+			"if_match": taskETag(t, id)},
+		map[string]any{"op": "delete", "id": "999999"},
+	}}, http.StatusNotFound)
+	_, status, _ = requestIfMatch(t, "api/task/done?id="+id, nil, http.MethodPost, taskETag(t, id))
+	assert.Equal(t, http.StatusOK, status)
+
+	entries := auditEntries(t, url.Values{"task_id": {id}})
+	if assert.Len(t, entries, 5) {
+		assert.Equal(t, "done", entries[0]["action"])
+		assert.Equal(t, "POST /api/task/done", entries[0]["endpoint"])
+		assert.NotEmpty(t, entries[0]["remote_addr"])
+		assert.Equal(t, "update", entries[1]["action"])
+		assert.Equal(t, "POST /api/tasks/batch", entries[1]["endpoint"])
+		assert.Equal(t, float64(http.StatusNotFound), entries[1]["status"])
+		assert.Nil(t, entries[1]["diff"])
+		assert.NotEmpty(t, entries[2]["error"])
+		assert.Nil(t, entries[2]["diff"])
+		assert.Equal(t, map[string]any{"before": "Заполнить анкету", "after": "Заполнить и отправить анкету"},
+			entries[3]["diff"].(map[string]any)["title"])
+		assert.Equal(t, "create", entries[4]["action"])
+		assert.Equal(t, float64(http.StatusCreated), entries[4]["status"])
+		assert.Equal(t, map[string]any{"before": nil, "after": "Заполнить анкету"},
+			entries[4]["diff"].(map[string]any)["title"])
+		//the task done for the last time is gone
+		assert.Equal(t, map[string]any{"before": "Заполнить и отправить анкету", "after": nil},
+			entries[0]["diff"].(map[string]any)["title"])
+	}
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("20060102")
+	assert.Len(t, auditEntries(t, url.Values{"task_id": {id}, "from": {today}, "to": {today}}), 5)
+	assert.Empty(t, auditEntries(t, url.Values{"task_id": {id}, "from": {tomorrow}}))
+	assert.Len(t, auditEntries(t, url.Values{"task_id": {id}, "limit": {"2"}}), 2)
+
+	//the failed sign in is recorded with the login tried
+	login := fmt.Sprint("mallory", time.Now().UnixNano())
+	//without the password authentication is disabled and nobody signs in
+	expected := http.StatusForbidden
+	if len(os.Getenv("TODO_PASSWORD")) > 0 {
+		expected = http.StatusUnauthorized
+	}
+	_, status, err = requestAs("", "api/signin", map[string]any{"login": login, "password": "guess"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, status)
+	entries = auditEntries(t, url.Values{"actor": {login}})
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "signin", entries[0]["action"])
+		assert.Equal(t, float64(expected), entries[0]["status"])
+	}
+
+	if len(os.Getenv("TODO_PASSWORD")) > 0 {
+		editor := registerUser(t, fmt.Sprint("trent", time.Now().UnixNano()), "trent-password")
+		_, status, err = requestAs(editor, "api/audit", nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, status)
+	}
+}
+
+func TestAuditImportRestore(t *testing.T) {
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("20060102")
+	ics := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VTODO\r\nUID:audit-1\r\n" +
+		"DTSTART;VALUE=DATE:" + tomorrow + "\r\nSUMMARY:Оплатить счета\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+	body, err := postBody("api/import/ics", "text/calendar", []byte(ics))
+	assert.NoError(t, err)
+	var report struct {
+		Created []struct {
+			Id int `json:"id"`
+		} `json:"created"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &report))
+	if !assert.Len(t, report.Created, 1) {
+		return
+	}
+	id := fmt.Sprint(report.Created[0].Id)
+	defer requestAs(Token, "api/task?id="+id, nil, http.MethodDelete)
+
+	entries := auditEntries(t, url.Values{"task_id": {id}})
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "import", entries[0]["action"])
+		assert.Equal(t, "POST /api/import/ics", entries[0]["endpoint"])
+		assert.Equal(t, float64(http.StatusOK), entries[0]["status"])
+		assert.Equal(t, map[string]any{"before": nil, "after": "Оплатить счета"},
+			entries[0]["diff"].(map[string]any)["title"])
+	}
+
+	_, status, err := requestAs(Token, "api/task?id="+id, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	_, status, err = requestAs(Token, "api/task/restore?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	//the task isn't in the trash anymore
+	_, status, err = requestAs(Token, "api/task/restore?id="+id, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	entries = auditEntries(t, url.Values{"task_id": {id}})
+	if assert.Len(t, entries, 4) {
+		assert.Equal(t, "restore", entries[0]["action"])
+		assert.Equal(t, float64(http.StatusNotFound), entries[0]["status"])
+		assert.NotEmpty(t, entries[0]["error"])
+		assert.Equal(t, "restore", entries[1]["action"])
+		assert.Equal(t, "POST /api/task/restore", entries[1]["endpoint"])
+		assert.Equal(t, float64(http.StatusOK), entries[1]["status"])
+		assert.Equal(t, map[string]any{"before": nil, "after": "Оплатить счета"},
+			entries[1]["diff"].(map[string]any)["title"])
+		assert.Equal(t, "delete", entries[2]["action"])
+	}
+
+	ret, status, err := requestAs(Token, "api/task/"+id+"/items", map[string]any{"title": "Электричество"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status, ret["error"])
+	entries = auditEntries(t, url.Values{"task_id": {id}, "limit": {"1"}})
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "item_create", entries[0]["action"])
+		assert.Equal(t, float64(http.StatusCreated), entries[0]["status"])
+		assert.Equal(t, map[string]any{"before": nil, "after": "Электричество"},
+			entries[0]["diff"].(map[string]any)["title"])
+	}
+
+	//the rejected authentication is recorded without the actor
+	if len(os.Getenv("TODO_PASSWORD")) > 0 {
+		_, status, err = requestAs("forged", "api/tasks", nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, status)
+		entries = auditEntries(t, url.Values{"limit": {"1"}})
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, "denied", entries[0]["action"])
+			assert.Equal(t, "GET /api/tasks", entries[0]["endpoint"])
+			assert.Equal(t, float64(http.StatusUnauthorized), entries[0]["status"])
+			assert.Empty(t, entries[0]["actor"])
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func batchResults(t *testing.T, body map[string]any, expected int) []map[string]any {
+	ret, status, err := requestAs(Token, "api/tasks/batch", body, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, status, ret["error"])
+	results := []map[string]any{}
+	list, _ := ret["results"].([]any)
+	for _, result := range list {
+		results = append(results, result.(map[string]any))
+	}
+	return results
+}
+
+func TestBatch(t *testing.T) {
+	today := time.Now().Format("20060102")
+	word := fmt.Sprint("batch", time.Now().UnixNano())
+
+	batchResults(t, map[string]any{"mode": "sometimes", "operations": []any{}}, http.StatusBadRequest)
+	batchResults(t, map[string]any{"operations": []any{}}, http.StatusBadRequest)
+
+	//the failed operation rolls the whole batch back
+	results := batchResults(t, map[string]any{"operations": []any{
+		map[string]any{"op": "create", "task": map[string]any{"title": "Полить цветы " + word, "date": today}},
+		map[string]any{"op": "done", "id": "999999", "if_match": "*"},
+		map[string]any{"op": "create", "task": map[string]any{"title": "Купить хлеб " + word, "date": today}},
+	}}, http.StatusNotFound)
+	if assert.Len(t, results, 3) {
+		assert.Nil(t, results[0]["id"])
+		assert.NotEmpty(t, results[0]["error"])
+		assert.NotEmpty(t, results[1]["error"])
+		assert.NotEmpty(t, results[2]["error"])
+	}
+	titles, _ := searchTitles(t, word)
+	assert.Empty(t, titles)
+
+	//the failed operations are skipped in the best effort mode
+	results = batchResults(t, map[string]any{"mode": "best_effort", "operations": []any{
+		map[string]any{"op": "create", "task": map[string]any{"title": "Полить цветы " + word, "date": today}},
+		map[string]any{"op": "create", "task": map[string]any{"title": "Купить хлеб " + word, "date": today}},
+		map[string]any{"op": "create", "task": map[string]any{"title": "", "date": today}},
+		map[string]any{"op": "delete", "id": "999999"},
+		map[string]any{"op": "archive", "id": "1"},
+	}}, http.StatusOK)
+	if !assert.Len(t, results, 5) {
+		return
+	}
+	flowers, bread := fmt.Sprint(results[0]["id"]), fmt.Sprint(results[1]["id"])
+	defer requestAs(Token, "api/task?id="+flowers, nil, http.MethodDelete)
+	defer requestAs(Token, "api/task?id="+bread, nil, http.MethodDelete)
+	assert.Nil(t, results[0]["error"])
+	assert.Nil(t, results[1]["error"])
+	for _, result := range results[2:] {
+		assert.NotEmpty(t, result["error"])
+	}
+	titles, _ = searchTitles(t, word)
+	assert.ElementsMatch(t, []string{"Полить цветы " + word, "Купить хлеб " + word}, titles)
+
+	results = batchResults(t, map[string]any{"operations": []any{
+		map[string]any{"op": "update", "id": flowers, "task": map[string]any{"title": "Полить кактус " + word,
+			"date": today, "repeat": "d 3"}, "if_match": taskETag(t, flowers)},
+		//the version of the task updated by the batch isn't known before it
+		map[string]any{"op": "done", "id": flowers, "if_match": "*"},
+		map[string]any{"op": "done", "id": bread, "if_match": taskETag(t, bread)},
+	}}, http.StatusOK)
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, atoi(flowers), atoi(fmt.Sprint(results[0]["id"])))
+		assert.Nil(t, results[2]["error"])
+	}
+	titles, _ = searchTitles(t, word)
+	assert.Equal(t, []string{"Полить кактус " + word}, titles)
+	ret, _, err := requestAs(Token, "api/task?id="+flowers, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Now().AddDate(0, 0, 3).Format("20060102"), ret["date"])
+}
+
+func TestBatchSkippedOperation(t *testing.T) {
+	today := time.Now().Format("20060102")
+	word := fmt.Sprint("skipped", time.Now().UnixNano())
+	id := addTask(t, task{date: today, title: "Вынести мусор " + word, repeat: "d 2"})
+	defer requestAs(Token, "api/task?id="+id, nil, http.MethodDelete)
+
+	before, _, etag := requestIfMatch(t, "api/task?id="+id, nil, http.MethodGet, "")
+	history := getCompletions(t, "api/task/history?id="+id)
+
+	//the skipped operation leaves neither the completion nor the changes of the task
+	results := batchResults(t, map[string]any{"mode": "best_effort", "operations": []any{
+		map[string]any{"op": "done", "id": id, "if_match": `"999"`},
+		map[string]any{"op": "create", "task": map[string]any{"title": "Купить хлеб " + word, "date": today}},
+	}}, http.StatusOK)
+	if !assert.Len(t, results, 2) {
+		return
+	}
+	defer requestAs(Token, "api/task?id="+fmt.Sprint(results[1]["id"]), nil, http.MethodDelete)
+	assert.NotEmpty(t, results[0]["error"])
+	assert.Nil(t, results[1]["error"])
+
+	after, _, etagAfter := requestIfMatch(t, "api/task?id="+id, nil, http.MethodGet, "")
+	assert.Equal(t, before, after)
+	assert.Equal(t, etag, etagAfter)
+	assert.Equal(t, history, getCompletions(t, "api/task/history?id="+id))
+	titles, _ := searchTitles(t, word)
+	assert.ElementsMatch(t, []string{"Вынести мусор " + word, "Купить хлеб " + word}, titles)
+}
@@ -0,0 +1,51 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendarExport(t *testing.T) {
+	now := time.Now()
+	id := addTask(t, task{
+		date:    now.Format(`20060102`),
+		title:   "Планёрка, еженедельная",
+		comment: "Переговорная №2",
+		repeat:  "w 1,4",
+	})
+
+	//the feed requires the calendar key of the user if the server has a password
+	feed := "api/calendar.ics?token="
+	if len(os.Getenv("TODO_PASSWORD")) > 0 {
+		_, readKey := createKey(t, "чтение", "read")
+		_, status, err := requestAs("", "api/calendar.ics?token="+url.QueryEscape(readKey), nil, http.MethodGet)
+		assert.Equal(t, http.StatusForbidden, status, err)
+
+		_, calendarKey := createKey(t, "календарь", "calendar")
+		_, status, err = requestWithKey(calendarKey, "api/tasks", nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, status, "Ключ календаря не должен открывать остальные адреса API")
+		feed += url.QueryEscape(calendarKey)
+	}
+	body, err := getBody(feed)
+	assert.NoError(t, err)
+	ics := string(body)
+
+	assert.True(t, strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\n"))
+	assert.True(t, strings.HasSuffix(ics, "END:VCALENDAR\r\n"))
+	assert.Contains(t, ics, "UID:task-"+id+"@")
+	assert.Contains(t, ics, `SUMMARY:Планёрка\, еженедельная`)
+	assert.Contains(t, ics, "DESCRIPTION:Переговорная №2")
+	assert.Contains(t, ics, "DTSTART;VALUE=DATE:"+now.Format(`20060102`))
+	assert.Contains(t, ics, "RRULE:FREQ=WEEKLY;BYDAY=MO,TH")
+
+	body, err = getBody(feed + "&type=todo")
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "BEGIN:VTODO")
+}
@@ -16,6 +16,15 @@ type Task struct {
 	Title   string `db:"title"`
 	Comment string `db:"comment"`
 	Repeat  string `db:"repeat"`
+
+	DeletedAt string `db:"deleted_at"`
+	UserId    int64  `db:"user_id"`
+	ProjectId int64  `db:"project_id"`
+	Priority  int64  `db:"priority"`
+	Time      string `db:"time_of_day"`
+	TimeZone  string `db:"timezone"`
+	Reminders string `db:"reminders"`
+	Version   int64  `db:"version"`
 }
 
 func count(db *sqlx.DB) (int, error) {
@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func addDependency(t *testing.T, taskId string, blockerId string) int {
+	ret, status, err := requestAs(Token, "api/task/dependencies", map[string]any{"task_id": atoi(taskId),
+		"blocker_id": atoi(blockerId)}, http.MethodPost)
+	assert.NoError(t, err)
+	if status != http.StatusOK {
+		assert.NotEmpty(t, ret["error"])
+	}
+	return status
+}
+
+func blockedTasks(t *testing.T, word string) []string {
+	ret, status, err := requestAs(Token, "api/tasks?"+url.Values{"search": {word}}.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status, ret["error"])
+	blocked := []string{}
+	tasks, _ := ret["tasks"].([]any)
+	for _, task := range tasks {
+		if m := task.(map[string]any); m["blocked"] == true {
+			blocked = append(blocked, fmt.Sprint(m["title"]))
+		}
+	}
+	return blocked
+}
+
+func atoi(s string) int {
+	var n int
+	fmt.Sscan(s, &n)
+	return n
+}
+
+func TestDependencies(t *testing.T) {
+	today := time.Now().Format("20060102")
+	word := fmt.Sprint("deps", time.Now().UnixNano())
+	ids := map[string]string{}
+	for _, task := range []map[string]any{
+		{"title": "Купить краску"},
+		{"title": "Покрасить стены"},
+		{"title": "Повесить картины"},
+		{"title": "Пригласить гостей"},
+		{"title": "Вынести мусор", "repeat": "d 1"},
+		{"title": "Сдать отчёт"},
+	} {
+		title := task["title"].(string)
+		task["title"], task["date"] = title+" "+word, today
+		ret, status, err := requestAs(Token, "api/task", task, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, status, ret["error"])
+		ids[title] = fmt.Sprint(ret["id"])
+	}
+	paint, walls, pictures, guests := ids["Купить краску"], ids["Покрасить стены"], ids["Повесить картины"], ids["Пригласить гостей"]
+	trash, report := ids["Вынести мусор"], ids["Сдать отчёт"]
+	defer func() {
+		//the blocked tasks aren't left in the trash, the blockers go there first
+		for _, title := range []string{"Купить краску", "Вынести мусор", "Покрасить стены", "Повесить картины",
+			"Пригласить гостей", "Сдать отчёт"} {
+			requestAs(Token, "api/task?id="+ids[title], nil, http.MethodDelete)
+		}
+	}()
+
+	assert.Equal(t, http.StatusBadRequest, addDependency(t, walls, walls))
+	assert.Equal(t, http.StatusNotFound, addDependency(t, walls, "999999"))
+	assert.Equal(t, http.StatusOK, addDependency(t, walls, paint))
+	assert.Equal(t, http.StatusOK, addDependency(t, walls, paint))
+	assert.Equal(t, http.StatusOK, addDependency(t, pictures, walls))
+	assert.Equal(t, http.StatusOK, addDependency(t, guests, pictures))
+	//the cycles are found through the whole chain
+	assert.Equal(t, http.StatusConflict, addDependency(t, paint, walls))
+	assert.Equal(t, http.StatusConflict, addDependency(t, paint, guests))
+
+	ret, _, err := requestAs(Token, "api/task/dependencies?id="+walls, nil, http.MethodGet)
+	assert.NoError(t, err)
+	dependencies, _ := ret["dependencies"].([]any)
+	assert.Len(t, dependencies, 2)
+
+	ret, _, err = requestAs(Token, "api/task?id="+walls, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, true, ret["blocked"])
+	assert.ElementsMatch(t, []string{"Покрасить стены " + word, "Повесить картины " + word, "Пригласить гостей " + word},
+		blockedTasks(t, word))
+
+	ret, status, _ := requestIfMatch(t, "api/task/done?id="+walls, nil, http.MethodPost, taskETag(t, walls))
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Contains(t, ret["error"], paint)
+
+	//the done blocker unblocks the next task of the chain only
+	_, status, _ = requestIfMatch(t, "api/task/done?id="+paint, nil, http.MethodPost, taskETag(t, paint))
+	assert.Equal(t, http.StatusOK, status)
+	ret, _, err = requestAs(Token, "api/task?id="+walls, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Nil(t, ret["blocked"])
+	assert.ElementsMatch(t, []string{"Повесить картины " + word, "Пригласить гостей " + word}, blockedTasks(t, word))
+
+	//the blocked task is done anyway with force
+	_, status, _ = requestIfMatch(t, "api/task/done?id="+guests+"&force=true", nil, http.MethodPost, taskETag(t, guests))
+	assert.Equal(t, http.StatusOK, status)
+
+	_, status, err = requestAs(Token, "api/task/dependencies?task_id="+pictures+"&blocker_id="+walls, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	_, status, err = requestAs(Token, "api/task/dependencies?task_id="+pictures+"&blocker_id="+walls, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Empty(t, blockedTasks(t, word))
+
+	//the repeating blocker is open until it moves past the date of the task
+	assert.Equal(t, http.StatusOK, addDependency(t, report, trash))
+	assert.Equal(t, []string{"Сдать отчёт " + word}, blockedTasks(t, word))
+	_, status, _ = requestIfMatch(t, "api/task/done?id="+trash, nil, http.MethodPost, taskETag(t, trash))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, blockedTasks(t, word))
+}
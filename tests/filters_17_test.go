@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func filterTitles(t *testing.T, query string) []string {
+	page := getTasksPage(t, "api/tasks?"+query)
+	titles := make([]string, 0, len(page.Tasks))
+	for _, task := range page.Tasks {
+		titles = append(titles, task["title"])
+	}
+	assert.Equal(t, len(titles), page.Total, query)
+	return titles
+}
+
+func TestFilters(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM scheduler")
+	assert.NoError(t, err)
+
+	now := time.Now()
+	day := func(days int) string {
+		return now.AddDate(0, 0, days).Format(`20060102`)
+	}
+	//overdue tasks can't be created by API, the date is moved to the past directly
+	overdue := addTask(t, task{date: day(0), title: "Просроченная", repeat: ""})
+	_, err = db.Exec("UPDATE scheduler SET date = ? WHERE id = ?", day(-3), overdue)
+	assert.NoError(t, err)
+	addTask(t, task{date: day(0), title: "Сегодня", repeat: "d 1"})
+	addTask(t, task{date: day(2), title: "Через два дня", repeat: "w 1,3"})
+	addTask(t, task{date: day(5), title: "Через пять дней", repeat: "FREQ=MONTHLY;BYMONTHDAY=1"})
+	addTask(t, task{date: day(10), title: "Через десять дней отчёт", repeat: "y"})
+
+	assert.Equal(t, []string{"Просроченная"}, filterTitles(t, "overdue=true"))
+	assert.Equal(t, []string{"Сегодня", "Через два дня"}, filterTitles(t, "from="+day(0)+"&to="+day(4)))
+	assert.Equal(t, []string{"Через пять дней", "Через десять дней отчёт"}, filterTitles(t, "from="+day(5)))
+	assert.Equal(t, []string{"Просроченная", "Сегодня"}, filterTitles(t, "to="+day(0)))
+
+	assert.Equal(t, []string{"Просроченная"}, filterTitles(t, "repeat=none"))
+	assert.Len(t, filterTitles(t, "repeat=any"), 4)
+	assert.Equal(t, []string{"Сегодня"}, filterTitles(t, "repeat=d"))
+	assert.Equal(t, []string{"Через два дня"}, filterTitles(t, "repeat=w"))
+	assert.Equal(t, []string{"Через пять дней"}, filterTitles(t, "repeat=m"))
+	assert.Equal(t, []string{"Через десять дней отчёт"}, filterTitles(t, "repeat=y"))
+
+	assert.Equal(t, []string{"Через десять дней отчёт"}, filterTitles(t, "search="+url.QueryEscape("отчёт")+"&repeat=any"))
+	assert.Empty(t, filterTitles(t, "search="+url.QueryEscape("отчёт")+"&to="+day(5)))
+	assert.Empty(t, filterTitles(t, "overdue=true&repeat=any"))
+
+	for _, query := range []string{"from=2024-01-01", "to=abc", "repeat=q", "overdue=maybe"} {
+		body, err := requestJSON("api/tasks?"+query, nil, http.MethodGet)
+		assert.NoError(t, err)
+		var m map[string]any
+		err = json.Unmarshal(body, &m)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, m["error"], query)
+	}
+}
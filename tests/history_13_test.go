@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type completion struct {
+	Id          string `json:"id"`
+	TaskId      string `json:"task_id"`
+	Title       string `json:"title"`
+	Date        string `json:"date"`
+	CompletedAt string `json:"completed_at"`
+}
+
+func getCompletions(t *testing.T, apipath string) []completion {
+	body, err := requestJSON(apipath, nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var m map[string][]completion
+	err = json.Unmarshal(body, &m)
+	assert.NoError(t, err)
+	return m["completions"]
+}
+
+func TestHistory(t *testing.T) {
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Полить цветы",
+		repeat: "d 3",
+	})
+	for i := 0; i < 2; i++ {
+		ret, _, _ := requestIfMatch(t, "api/task/done?id="+id, nil, http.MethodPost, taskETag(t, id))
+		assert.Empty(t, ret)
+	}
+
+	history := getCompletions(t, "api/task/history?id="+id)
+	assert.Len(t, history, 2)
+	if len(history) == 2 {
+		assert.Equal(t, id, history[0].TaskId)
+		assert.Equal(t, "Полить цветы", history[0].Title)
+		assert.Equal(t, now.AddDate(0, 0, 3).Format(`20060102`), history[0].Date)
+		assert.Equal(t, now.Format(`20060102`), history[1].Date)
+		assert.NotEmpty(t, history[0].CompletedAt)
+	}
+
+	single := addTask(t, task{
+		date:  now.Format(`20060102`),
+		title: "Вынести мусор",
+	})
+	ret, _, _ := requestIfMatch(t, "api/task/done?id="+single, nil, http.MethodPost, taskETag(t, single))
+	assert.Empty(t, ret)
+	notFoundTask(t, single)
+	assert.Len(t, getCompletions(t, "api/task/history?id="+single), 1)
+
+	today := now.Format(`20060102`)
+	found := 0
+	for _, c := range getCompletions(t, "api/history?from="+today+"&to="+today) {
+		if c.TaskId == id || c.TaskId == single {
+			found++
+		}
+	}
+	assert.Equal(t, 3, found)
+
+	tomorrow := now.AddDate(0, 0, 1).Format(`20060102`)
+	assert.Empty(t, getCompletions(t, "api/history?from="+tomorrow))
+
+	ret, err := postJSON("api/history?from=ooops", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, ret["error"])
+}
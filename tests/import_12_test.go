@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func postBody(apipath string, contentType string, data []byte) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, getURL(apipath), bytes.NewBuffer(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func TestCalendarImport(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	tomorrow := time.Now().AddDate(0, 0, 1).Format(`20060102`)
+	ics := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"UID:import-1\r\n" +
+		"DTSTART;VALUE=DATE:" + tomorrow + "\r\n" +
+		"SUMMARY:Стендап\\, команда\r\n" +
+		"DESCRIPTION:строка 1\\nстрока 2\r\n" +
+		"RRULE:FREQ=WEEKLY;BYDAY=MO,TH\r\n" +
+		"END:VEVENT\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:import-2\r\n" +
+		"DUE;TZID=\"Europe/Moscow\":" + tomorrow + "T100000\r\n" +
+		"SUMMARY:Очень длинный заголовок задачи\\, который был\r\n" +
+		"  перенесён на следующую строку\r\n" +
+		"RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1\r\n" +
+		"END:VTODO\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:import-3\r\n" +
+		"DTSTART:" + tomorrow + "\r\n" +
+		"END:VTODO\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:import-4\r\n" +
+		"SUMMARY:Сделано\r\n" +
+		"STATUS:COMPLETED\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+
+	body, err := postBody("api/import/ics", "text/calendar", []byte(ics))
+	assert.NoError(t, err)
+
+	var report struct {
+		Created []struct {
+			Index int    `json:"index"`
+			Uid   string `json:"uid"`
+			Id    int64  `json:"id"`
+		} `json:"created"`
+		Rejected []struct {
+			Index int    `json:"index"`
+			Uid   string `json:"uid"`
+			Error string `json:"error"`
+		} `json:"rejected"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.Len(t, report.Created, 2)
+	assert.Len(t, report.Rejected, 2)
+	if len(report.Created) != 2 || len(report.Rejected) != 2 {
+		return
+	}
+	assert.Equal(t, "import-3", report.Rejected[0].Uid)
+	assert.NotEmpty(t, report.Rejected[0].Error)
+	assert.Equal(t, "import-4", report.Rejected[1].Uid)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, report.Created[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Стендап, команда", task.Title)
+	assert.Equal(t, "строка 1\nстрока 2", task.Comment)
+	assert.Equal(t, "w 1,4", task.Repeat)
+
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, report.Created[1].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Очень длинный заголовок задачи, который был перенесён на следующую строку", task.Title)
+	assert.Equal(t, "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", task.Repeat)
+}
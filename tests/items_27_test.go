@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func taskItems(t *testing.T, id string) []map[string]any {
+	ret, status, err := requestAs(Token, "api/task/"+id+"/items", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status, ret["error"])
+	items := []map[string]any{}
+	list, _ := ret["items"].([]any)
+	for _, item := range list {
+		items = append(items, item.(map[string]any))
+	}
+	return items
+}
+
+func TestItems(t *testing.T) {
+	today := time.Now().Format("20060102")
+	ret, status, err := requestAs(Token, "api/task", map[string]any{"title": "Уборка", "date": today,
+		"repeat": "d 7"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status, ret["error"])
+	id := fmt.Sprint(ret["id"])
+	defer requestAs(Token, "api/task?id="+id, nil, http.MethodDelete)
+
+	_, status, err = requestAs(Token, "api/task/"+id+"/items", map[string]any{"title": " "}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+	_, status, err = requestAs(Token, "api/task/999999/items", map[string]any{"title": "Пыль"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	//the ids are kept as numbers returned by the API
+	ids := []any{}
+	for _, title := range []string{"Пропылесосить", "Вытереть пыль", "Полить цветы"} {
+		ret, status, err := requestAs(Token, "api/task/"+id+"/items", map[string]any{"title": title}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, status, ret["error"])
+		ids = append(ids, ret["id"])
+	}
+	items := taskItems(t, id)
+	if assert.Len(t, items, 3) {
+		assert.Equal(t, "Пропылесосить", items[0]["title"])
+		assert.Equal(t, false, items[0]["done"])
+	}
+
+	for _, itemId := range ids[:2] {
+		ret, status, err := requestAs(Token, "api/task/"+id+"/items", map[string]any{"id": itemId,
+			"title": "Сделано", "done": true}, http.MethodPut)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, status, ret["error"])
+	}
+	_, status, err = requestAs(Token, "api/task/"+id+"/items?item_id="+fmt.Sprint(ids[2]), nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	_, status, err = requestAs(Token, "api/task/"+id+"/items?item_id="+fmt.Sprint(ids[2]), nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	//the checklist is returned with the task
+	ret, _, err = requestAs(Token, "api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	list, _ := ret["items"].([]any)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, true, list[0].(map[string]any)["done"])
+		assert.Equal(t, "Сделано", list[1].(map[string]any)["title"])
+	}
+
+	//the done repeating task moves to the next date with the checklist reset
+	_, status, _ = requestIfMatch(t, "api/task/done?id="+id, nil, http.MethodPost, taskETag(t, id))
+	assert.Equal(t, http.StatusOK, status)
+	ret, _, err = requestAs(Token, "api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Now().AddDate(0, 0, 7).Format("20060102"), ret["date"])
+	for _, item := range taskItems(t, id) {
+		assert.Equal(t, false, item["done"])
+	}
+}
@@ -0,0 +1,20 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrations(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	var versions []int
+	err := db.Select(&versions, `SELECT version FROM schema_migrations ORDER BY version`)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, versions, "Ожидается хотя бы одна применённая миграция")
+	for i, v := range versions {
+		assert.Equal(t, i+1, v, "Миграции должны применяться последовательно")
+	}
+}
@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateIntervals(t *testing.T) {
+	if !FullNextDate {
+		return
+	}
+	tbl := []nextDate{
+		{"20240126", "y 3", "20270126"},
+		{"20200101", "y 3", "20260101"},
+		{"20240126", "y 0", ""},
+		{"20240126", "y x", ""},
+		{"20240126", "y 1 2", ""},
+		{"20240108", "w 1,4 /2", "20240205"},
+		{"20240101", "w 7 /4", "20240204"},
+		{"20240108", "w 1,4 /1", "20240129"},
+		{"20240108", "w 1,4 2", ""},
+		{"20240108", "w 1,4 /0", ""},
+		{"20240108", "w 1,4 /53", ""},
+		{"20240126", "m 2#2", "20240213"},
+		{"20240126", "m 5#-1 3,9", "20240329"},
+		{"20240401", "m 5#-1 3,9", "20240927"},
+		{"20240126", "m 7#1", "20240204"},
+		{"20240126", "m 1,1#1", "20240201"},
+		{"20240126", "m 8#1", ""},
+		{"20240126", "m 2#6", ""},
+		{"20240126", "m 2#0", ""},
+		{"20240126", "m 2#", ""},
+		{"20240126", "m 31 2", ""},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q}`,
+			v.date, v.repeat, v.want)
+	}
+}
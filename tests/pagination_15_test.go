@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tasksPage struct {
+	Tasks      []map[string]string `json:"tasks"`
+	NextCursor string              `json:"next_cursor"`
+	Total      int                 `json:"total"`
+}
+
+func getTasksPage(t *testing.T, url string) tasksPage {
+	body, err := requestJSON(url, nil, http.MethodGet)
+	assert.NoError(t, err)
+
+	var page tasksPage
+	err = json.Unmarshal(body, &page)
+	assert.NoError(t, err)
+	return page
+}
+
+func TestPagination(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM scheduler")
+	assert.NoError(t, err)
+
+	now := time.Now()
+	ids := make(map[string]bool)
+	//two tasks on each date to check the order by id inside the date
+	for i := 0; i < 5; i++ {
+		date := now.AddDate(0, 0, i).Format(`20060102`)
+		for j := 0; j < 2; j++ {
+			id := addTask(t, task{date: date, title: "Страница page"})
+			ids[id] = true
+		}
+	}
+
+	var seen []map[string]string
+	url := "api/tasks?limit=3"
+	for pages := 0; ; pages++ {
+		if !assert.Less(t, pages, 4) {
+			break
+		}
+		page := getTasksPage(t, url)
+		assert.Equal(t, 10, page.Total)
+		assert.LessOrEqual(t, len(page.Tasks), 3)
+		seen = append(seen, page.Tasks...)
+		if len(page.NextCursor) == 0 {
+			break
+		}
+		url = "api/tasks?limit=3&cursor=" + page.NextCursor
+	}
+
+	assert.Len(t, seen, 10)
+	for i, task := range seen {
+		assert.True(t, ids[task["id"]])
+		delete(ids, task["id"])
+		if i > 0 {
+			assert.LessOrEqual(t, seen[i-1]["date"], task["date"])
+		}
+	}
+
+	page := getTasksPage(t, "api/tasks?limit=2&search=page")
+	assert.Equal(t, 10, page.Total)
+	assert.Len(t, page.Tasks, 2)
+	assert.NotEmpty(t, page.NextCursor)
+
+	for _, url := range []string{"api/tasks?limit=0", "api/tasks?limit=abc", "api/tasks?cursor=%21%21"} {
+		body, err := requestJSON(url, nil, http.MethodGet)
+		assert.NoError(t, err)
+		var m map[string]any
+		err = json.Unmarshal(body, &m)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, m["error"], url)
+	}
+}
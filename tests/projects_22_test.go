@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func projectTaskTitles(t *testing.T, token string, project string) []string {
+	ret, status, err := requestAs(token, "api/tasks?"+url.Values{"project": {project}}.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	titles := []string{}
+	tasks, _ := ret["tasks"].([]any)
+	for _, task := range tasks {
+		m := task.(map[string]any)
+		assert.Equal(t, project, m["project_id"])
+		titles = append(titles, fmt.Sprint(m["title"]))
+	}
+	return titles
+}
+
+func TestProjects(t *testing.T) {
+	ret, status, err := requestAs(Token, "api/projects", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	projects, _ := ret["projects"].([]any)
+	var defaultId string
+	for _, p := range projects {
+		m := p.(map[string]any)
+		if m["is_default"] == true {
+			defaultId = fmt.Sprint(m["id"])
+			assert.Equal(t, "admin", m["role"])
+		}
+	}
+	assert.NotEmpty(t, defaultId)
+
+	_, status, err = requestAs(Token, "api/projects", map[string]any{"name": " "}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	ret, status, err = requestAs(Token, "api/projects", map[string]any{"name": "Ремонт"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status)
+	projectId := fmt.Sprint(ret["id"])
+
+	ret, status, err = requestAs(Token, "api/task", map[string]any{"title": "Купить краску", "project_id": projectId}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status, ret["error"])
+	id := fmt.Sprint(ret["id"])
+
+	_, status, err = requestAs(Token, "api/task", map[string]any{"title": "Без проекта", "project_id": "999999"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	//the task without the project goes to the default one
+	ret, status, err = requestAs(Token, "api/task", map[string]any{"title": "Позвонить маме"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status)
+	defaultTask := fmt.Sprint(ret["id"])
+	ret, _, err = requestAs(Token, "api/task?id="+defaultTask, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultId, ret["project_id"])
+
+	assert.Equal(t, []string{"Купить краску"}, projectTaskTitles(t, Token, projectId))
+	assert.Contains(t, projectTaskTitles(t, Token, defaultId), "Позвонить маме")
+
+	_, status, err = requestAs(Token, "api/tasks?project=abc", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	//the task is moved to the other project by update
+	ret, status, _ = requestIfMatch(t, "api/task", map[string]any{"id": defaultTask, "title": "Позвонить маме",
+		"date": time.Now().Format("20060102"), "project_id": projectId}, http.MethodPut, taskETag(t, defaultTask))
+	assert.Equal(t, http.StatusOK, status, ret["error"])
+	assert.ElementsMatch(t, []string{"Купить краску", "Позвонить маме"}, projectTaskTitles(t, Token, projectId))
+
+	_, status, err = requestAs(Token, "api/projects", map[string]any{"id": projectId, "name": "Ремонт кухни"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	var projectIdInt int
+	fmt.Sscan(projectId, &projectIdInt)
+	_, status, err = requestAs(Token, "api/projects", map[string]any{"id": projectIdInt, "name": "Ремонт кухни"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	ret, _, err = requestAs(Token, "api/projects?id="+projectId, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, "Ремонт кухни", ret["name"])
+
+	if len(os.Getenv("TODO_PASSWORD")) > 0 {
+		testProjectMembers(t, projectIdInt, id)
+	}
+
+	_, status, err = requestAs(Token, "api/projects?id="+defaultId, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	_, status, err = requestAs(Token, "api/projects?id="+projectId, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	_, status, err = requestAs(Token, "api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func testProjectMembers(t *testing.T, projectId int, taskId string) {
+	login := fmt.Sprint("dave", time.Now().UnixNano())
+	dave := registerUser(t, login, "dave-password")
+	project := fmt.Sprint(projectId)
+
+	_, status, err := requestAs(dave, "api/task?id="+taskId, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	_, status, err = requestAs(Token, "api/projects/members", map[string]any{"project_id": projectId, "login": login, "role": "viewer"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	ret, status, err := requestAs(dave, "api/projects/members?project_id="+project, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	members, _ := ret["members"].([]any)
+	assert.Len(t, members, 2)
+
+	//viewers of the project see its tasks, but don't change them
+	ret, status, err = requestAs(dave, "api/task?id="+taskId, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Купить краску", ret["title"])
+	assert.Contains(t, projectTaskTitles(t, dave, project), "Купить краску")
+
+	_, status, err = requestAs(dave, "api/task/done?id="+taskId, nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	_, status, err = requestAs(dave, "api/task", map[string]any{"title": "Чужая задача", "project_id": project}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	_, status, err = requestAs(dave, "api/projects/members", map[string]any{"project_id": projectId, "login": login, "role": "admin"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	_, status, err = requestAs(Token, "api/projects/members", map[string]any{"project_id": projectId, "login": login, "role": "editor"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	ret, status, err = requestAs(dave, "api/task", map[string]any{"title": "Задача Дейва", "project_id": project}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status, ret["error"])
+
+	//the owner keeps the admin role
+	_, status, err = requestAs(Token, "api/projects/members", map[string]any{"project_id": projectId, "login": "admin", "role": "viewer"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	var daveId any
+	for _, m := range members {
+		if m.(map[string]any)["login"] == login {
+			daveId = m.(map[string]any)["user_id"]
+		}
+	}
+	_, status, err = requestAs(dave, fmt.Sprintf("api/projects/members?project_id=%s&user_id=%v", project, daveId), nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	_, status, err = requestAs(dave, "api/task?id="+taskId, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, status)
+}
@@ -0,0 +1,221 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// reminderStub receives the reminders sent to the webhook, the Telegram Bot API and the SMTP server
+type reminderStub struct {
+	mu       sync.Mutex
+	webhook  []map[string]any
+	telegram []string
+	emails   []string
+	//failed remembers the reminders rejected once by the webhook to check the retry
+	failed map[string]bool
+}
+
+func (s *reminderStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if strings.HasSuffix(r.URL.Path, "/sendMessage") {
+		var msg map[string]string
+		json.NewDecoder(r.Body).Decode(&msg)
+		s.telegram = append(s.telegram, msg["text"])
+		w.Write([]byte(`{"ok":true}`))
+		return
+	}
+	var reminder map[string]any
+	json.NewDecoder(r.Body).Decode(&reminder)
+	key := fmt.Sprint(reminder["task_id"], reminder["remind_at"])
+	if !s.failed[key] {
+		s.failed[key] = true
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	s.webhook = append(s.webhook, reminder)
+}
+
+// serveSMTP answers as the mail server which accepts any message
+func (s *reminderStub) serveSMTP(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 stub\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(cmd, "DATA"):
+			fmt.Fprint(conn, "354 go ahead\r\n")
+			var msg strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				msg.WriteString(line)
+			}
+			s.mu.Lock()
+			s.emails = append(s.emails, msg.String())
+			s.mu.Unlock()
+			fmt.Fprint(conn, "250 queued\r\n")
+		case strings.HasPrefix(cmd, "QUIT"):
+			fmt.Fprint(conn, "221 bye\r\n")
+			return
+		default:
+			fmt.Fprint(conn, "250 ok\r\n")
+		}
+	}
+}
+
+func (s *reminderStub) count(title string) (webhook int, telegram int, emails int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, reminder := range s.webhook {
+		if reminder["title"] == title {
+			webhook++
+		}
+	}
+	for _, text := range s.telegram {
+		if strings.Contains(text, title) {
+			telegram++
+		}
+	}
+	for _, email := range s.emails {
+		if strings.Contains(email, title) {
+			emails++
+		}
+	}
+	return
+}
+
+func TestReminders(t *testing.T) {
+	for _, reminders := range []string{"5x", "31d", "25:00", "1d,2d,3d,4d,5d,6d"} {
+		_, status, err := requestAs(Token, "api/task", map[string]any{"title": "Неверное напоминание",
+			"reminders": reminders}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, status, reminders)
+	}
+
+	webhookURL := os.Getenv("TODO_WEBHOOK_URL")
+	if len(webhookURL) == 0 {
+		t.Skip("Отправка напоминаний проверяется с заглушкой, задайте TODO_WEBHOOK_URL=http://localhost:7550/hook " +
+			"и TODO_REMINDER_INTERVAL=1s серверу и тестам, а также TODO_SMTP_ADDR и TODO_TELEGRAM_API на той же машине")
+	}
+	now := time.Now().UTC()
+	if now.Hour() == 23 && now.Minute() == 59 {
+		t.Skip("the reminders of today aren't sent after its end, checked a minute later")
+	}
+
+	stub := &reminderStub{failed: map[string]bool{}}
+	hook, err := url.Parse(webhookURL)
+	assert.NoError(t, err)
+	listener, err := net.Listen("tcp", hook.Host)
+	if !assert.NoError(t, err) {
+		return
+	}
+	server := &http.Server{Handler: stub}
+	go server.Serve(listener)
+	defer server.Close()
+	if addr := os.Getenv("TODO_SMTP_ADDR"); len(addr) > 0 {
+		smtpListener, err := net.Listen("tcp", addr)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer smtpListener.Close()
+		go func() {
+			for {
+				conn, err := smtpListener.Accept()
+				if err != nil {
+					return
+				}
+				go stub.serveSMTP(conn)
+			}
+		}()
+	}
+
+	//the task of today has both reminders passed, the task of tomorrow has none
+	title := fmt.Sprint("Позвонить ", time.Now().UnixNano())
+	ret, status, err := requestAs(Token, "api/task", map[string]any{"title": title, "date": now.Format("20060102"),
+		"time": now.Format("15:04"), "timezone": "UTC", "reminders": " 1d, 0m "}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status, ret["error"])
+	id := fmt.Sprint(ret["id"])
+	defer requestAs(Token, "api/task?id="+id, nil, http.MethodDelete)
+
+	ret, _, err = requestAs(Token, "api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, "1d,0m", ret["reminders"])
+
+	later := fmt.Sprint("Завтра ", time.Now().UnixNano())
+	ret, status, err = requestAs(Token, "api/task", map[string]any{"title": later,
+		"date": now.AddDate(0, 0, 1).Format("20060102"), "timezone": "UTC", "reminders": "1h"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status, ret["error"])
+	defer requestAs(Token, "api/task?id="+fmt.Sprint(ret["id"]), nil, http.MethodDelete)
+
+	//the channels are the ones of the server, the reminders of the other users aren't sent there
+	other := fmt.Sprint("Чужая задача ", time.Now().UnixNano())
+	if len(os.Getenv("TODO_PASSWORD")) > 0 && os.Getenv("TODO_REGISTRATION") == "true" {
+		token := registerUser(t, fmt.Sprint("erin", time.Now().UnixNano()), "erin-password")
+		ret, status, err = requestAs(token, "api/task", map[string]any{"title": other, "date": now.Format("20060102"),
+			"time": now.Format("15:04"), "timezone": "UTC", "reminders": "0m"}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, status, ret["error"])
+	}
+
+	//the webhook rejects every reminder once, so it's delivered by the next run
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if webhook, _, _ := stub.count(title); webhook == 2 {
+			break
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	//the delivered reminders aren't sent again by the next runs
+	time.Sleep(2 * time.Second)
+	webhook, telegram, emails := stub.count(title)
+	assert.Equal(t, 2, webhook)
+	if len(os.Getenv("TODO_TELEGRAM_API")) > 0 {
+		assert.Equal(t, 2, telegram)
+	}
+	if len(os.Getenv("TODO_SMTP_ADDR")) > 0 {
+		assert.Equal(t, 2, emails)
+	}
+	webhook, telegram, emails = stub.count(later)
+	assert.Zero(t, webhook+telegram+emails)
+	webhook, telegram, emails = stub.count(other)
+	assert.Zero(t, webhook+telegram+emails)
+
+	db := openDB(t)
+	defer db.Close()
+	deliveries := []struct {
+		Channel  string `db:"channel"`
+		SentAt   string `db:"sent_at"`
+		Attempts int    `db:"attempts"`
+	}{}
+	err = db.Select(&deliveries, `SELECT channel, sent_at, attempts FROM reminder_deliveries WHERE task_id = ?`, id)
+	assert.NoError(t, err)
+	webhookDeliveries := 0
+	for _, d := range deliveries {
+		assert.NotEmpty(t, d.SentAt)
+		if d.Channel == "webhook" {
+			webhookDeliveries++
+			assert.Equal(t, 2, d.Attempts)
+		}
+	}
+	assert.Equal(t, 2, webhookDeliveries)
+}
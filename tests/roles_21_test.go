@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoles(t *testing.T) {
+	if len(os.Getenv("TODO_PASSWORD")) == 0 {
+		t.Skip("Роли доступны только с паролем, задайте TODO_PASSWORD серверу и тестам")
+	}
+
+	ret, status, err := requestAs(Token, "api/users", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status, ret["error"])
+
+	login := fmt.Sprint("carol", time.Now().UnixNano())
+	carol := registerUser(t, login, "carol-password")
+
+	var id any
+	ret, _, err = requestAs(Token, "api/users", nil, http.MethodGet)
+	assert.NoError(t, err)
+	users, _ := ret["users"].([]any)
+	for _, u := range users {
+		m := u.(map[string]any)
+		assert.Nil(t, m["password_hash"])
+		if m["login"] == login {
+			id = m["id"]
+			assert.Equal(t, "editor", m["role"])
+		}
+	}
+	assert.NotNil(t, id)
+
+	//editors can't manage users
+	ret, status, err = requestAs(carol, "api/users", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.NotEmpty(t, ret["error"])
+
+	_, status, err = requestAs(Token, "api/users", map[string]any{"id": id, "role": "owner"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	_, status, err = requestAs(Token, "api/users", map[string]any{"id": 1, "role": "viewer"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	//the demotion limits the tokens issued before it
+	_, status, err = requestAs(Token, "api/users", map[string]any{"id": id, "role": "viewer"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	_, status, err = requestAs(carol, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	ret, status, err = requestAs(carol, "api/task", map[string]any{"title": "Задача зрителя"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.NotEmpty(t, ret["error"])
+
+	_, status, err = requestAs(Token, "api/users", map[string]any{"id": id, "role": "editor"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	ret, status, err = requestAs(carol, "api/task", map[string]any{"title": "Задача редактора"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status, ret["error"])
+
+	viewerPassword := os.Getenv("TODO_VIEWER_PASSWORD")
+	if len(viewerPassword) == 0 {
+		return
+	}
+	ret, status, err = requestAs("", "api/signin", map[string]any{"password": viewerPassword}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	viewer, _ := ret["token"].(string)
+
+	_, status, err = requestAs(viewer, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	_, status, err = requestAs(viewer, "api/task", map[string]any{"title": "Задача зрителя"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	//the refreshed token keeps the viewer role
+	ret, status, err = requestAs("", "api/token/refresh", map[string]any{"refresh_token": ret["refresh_token"]}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	viewer, _ = ret["token"].(string)
+
+	_, status, err = requestAs(viewer, "api/users", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	//the viewer can't get the key with more access than it has
+	for _, scope := range []string{"write", "read"} {
+		_, status, err = requestAs(viewer, "api/keys", map[string]any{"name": "ключ зрителя", "scope": scope}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, status, scope)
+	}
+}
+
+func TestViewerPasswordOnly(t *testing.T) {
+	viewerPassword := os.Getenv("TODO_VIEWER_PASSWORD")
+	if len(os.Getenv("TODO_PASSWORD")) > 0 || len(viewerPassword) == 0 {
+		t.Skip("Проверяется конфигурация только с паролем зрителя, задайте TODO_VIEWER_PASSWORD без TODO_PASSWORD серверу и тестам")
+	}
+
+	//authentication is disabled, the viewer password doesn't issue the tokens
+	ret, status, err := requestAs("", "api/signin", map[string]any{"password": viewerPassword}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.NotEmpty(t, ret["error"])
+
+	_, status, err = requestAs("", "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextDateRRule(t *testing.T) {
+	tbl := []nextDate{
+		{"20240113", "FREQ=DAILY;INTERVAL=7", "20240127"},
+		{"20240108", "FREQ=WEEKLY;BYDAY=MO,WE;INTERVAL=2", "20240205"},
+		{"20240110", "RRULE:FREQ=MONTHLY;BYMONTHDAY=-1", "20240131"},
+		{"20240110", "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", "20240131"},
+		{"20240101", "FREQ=YEARLY;BYMONTH=3,9;BYDAY=-1FR", "20240329"},
+		{"20231130", "FREQ=MONTHLY;BYMONTHDAY=31", "20240131"},
+		{"20231015", "FREQ=MONTHLY;INTERVAL=2", "20240215"},
+		{"20240101", "FREQ=DAILY;COUNT=30", "20240127"},
+		{"20240101", "FREQ=DAILY;COUNT=3", ""},
+		{"20240101", "FREQ=WEEKLY;UNTIL=20240210T000000Z", "20240129"},
+		{"20240101", "FREQ=WEEKLY;UNTIL=20240125", ""},
+		{"20240126", "FREQ=HOURLY", ""},
+		{"20240126", "FREQ=DAILY;COUNT=2;UNTIL=20240301", ""},
+		{"20240126", "FREQ=WEEKLY;BYDAY=2MO", ""},
+		{"20240126", "FREQ=DAILY;BYFOO=1", ""},
+		{"20240126", "FREQ=MONTHLY;BYMONTHDAY=32", ""},
+	}
+	for _, v := range tbl {
+		urlPath := fmt.Sprintf("api/nextdate?now=20240126&date=%s&repeat=%s",
+			url.QueryEscape(v.date), url.QueryEscape(v.repeat))
+		get, err := getBody(urlPath)
+		assert.NoError(t, err)
+		next := strings.TrimSpace(string(get))
+		_, err = time.Parse("20060102", next)
+		if err != nil && len(v.want) == 0 {
+			continue
+		}
+		assert.Equal(t, v.want, next, `{%q, %q, %q}`,
+			v.date, v.repeat, v.want)
+	}
+}
+
+func TestDoneRRuleCount(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	id := addTask(t, task{
+		date:   now.Format(`20060102`),
+		title:  "Принять лекарство",
+		repeat: "FREQ=DAILY;INTERVAL=2;COUNT=3",
+	})
+
+	for i := 2; i > 0; i-- {
+		ret, _, _ := requestIfMatch(t, "api/task/done?id="+id, nil, http.MethodPost, taskETag(t, id))
+		assert.Empty(t, ret)
+
+		var task Task
+		err := db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		assert.NoError(t, err)
+		now = now.AddDate(0, 0, 2)
+		assert.Equal(t, now.Format(`20060102`), task.Date)
+		assert.Equal(t, fmt.Sprintf("FREQ=DAILY;INTERVAL=2;COUNT=%d", i), task.Repeat)
+	}
+
+	ret, _, _ := requestIfMatch(t, "api/task/done?id="+id, nil, http.MethodPost, taskETag(t, id))
+	assert.Empty(t, ret)
+	notFoundTask(t, id)
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func searchTitles(t *testing.T, search string) ([]string, tasksPage) {
+	page := getTasksPage(t, "api/tasks?search="+url.QueryEscape(search))
+	titles := make([]string, 0, len(page.Tasks))
+	for _, task := range page.Tasks {
+		titles = append(titles, task["title"])
+	}
+	return titles, page
+}
+
+func TestSearch(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	_, err := db.Exec("DELETE FROM scheduler")
+	assert.NoError(t, err)
+
+	addTask(t, task{title: "Купить молоко", comment: "и хлеб к ужину"})
+	addTask(t, task{title: "Позвонить маме", comment: "про молоко"})
+	addTask(t, task{title: "Молочный коктейль"})
+	addTask(t, task{title: "Квартальный отчёт"})
+
+	//case of cyrillic letters is ignored by both full text search and LIKE
+	titles, page := searchTitles(t, "МОЛОКО")
+	assert.ElementsMatch(t, []string{"Купить молоко", "Позвонить маме"}, titles)
+	assert.Equal(t, 2, page.Total)
+
+	if len(page.Tasks) == 0 || len(page.Tasks[0]["snippet"]) == 0 {
+		t.Skip("Сервер собран без FTS5, проверки полнотекстового поиска пропущены")
+	}
+	for _, task := range page.Tasks {
+		assert.Contains(t, strings.ToLower(task["snippet"]), "<mark>молоко</mark>")
+	}
+
+	titles, _ = searchTitles(t, "мол*")
+	assert.ElementsMatch(t, []string{"Купить молоко", "Позвонить маме", "Молочный коктейль"}, titles)
+
+	titles, _ = searchTitles(t, `"купить молоко"`)
+	assert.Equal(t, []string{"Купить молоко"}, titles)
+
+	titles, _ = searchTitles(t, `"молоко купить"`)
+	assert.Empty(t, titles)
+
+	titles, _ = searchTitles(t, "молоко NOT маме")
+	assert.Equal(t, []string{"Купить молоко"}, titles)
+
+	titles, _ = searchTitles(t, "хлеб OR коктейль")
+	assert.ElementsMatch(t, []string{"Купить молоко", "Молочный коктейль"}, titles)
+
+	//the query syntax errors are not passed to the user
+	for _, search := range []string{`"молоко`, "NOT", "C++", "OR молоко AND"} {
+		_, page = searchTitles(t, search)
+		assert.NotNil(t, page.Tasks, search)
+	}
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sortedTitles walks all pages of the sorted list, the pages are small to check the cursor
+func sortedTitles(t *testing.T, query url.Values) []string {
+	query.Set("limit", "2")
+	titles := []string{}
+	for pages := 0; pages < 5; pages++ {
+		ret, status, err := requestAs(Token, "api/tasks?"+query.Encode(), nil, http.MethodGet)
+		assert.NoError(t, err)
+		if !assert.Equal(t, http.StatusOK, status, ret["error"]) {
+			break
+		}
+		tasks, _ := ret["tasks"].([]any)
+		for _, task := range tasks {
+			titles = append(titles, fmt.Sprint(task.(map[string]any)["title"]))
+		}
+		cursor, _ := ret["next_cursor"].(string)
+		if len(cursor) == 0 {
+			break
+		}
+		query.Set("cursor", cursor)
+	}
+	return titles
+}
+
+func TestSort(t *testing.T) {
+	tag := fmt.Sprint("sort", time.Now().UnixNano())
+	now := time.Now()
+	for _, task := range []map[string]any{
+		{"title": "Б|задача", "priority": "2", "date": now.AddDate(0, 0, 2).Format("20060102")},
+		{"title": "А задача", "priority": "1", "date": now.AddDate(0, 0, 3).Format("20060102")},
+		{"title": "В задача", "date": now.AddDate(0, 0, 1).Format("20060102")},
+		{"title": "Г задача", "priority": "2", "date": now.Format("20060102")},
+	} {
+		task["tags"] = []string{tag}
+		ret, status, err := requestAs(Token, "api/task", task, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, status, ret["error"])
+	}
+
+	for _, priority := range []string{"0", "5", "high"} {
+		_, status, err := requestAs(Token, "api/task", map[string]any{"title": "Задача", "priority": priority}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, status, priority)
+	}
+
+	assert.Equal(t, []string{"Г задача", "В задача", "Б|задача", "А задача"},
+		sortedTitles(t, url.Values{"tag": {tag}}))
+	assert.Equal(t, []string{"А задача", "Б|задача", "В задача", "Г задача"},
+		sortedTitles(t, url.Values{"tag": {tag}, "sort": {"date"}, "order": {"desc"}}))
+	//the tasks with the same priority are ordered by id
+	assert.Equal(t, []string{"А задача", "Б|задача", "Г задача", "В задача"},
+		sortedTitles(t, url.Values{"tag": {tag}, "sort": {"priority"}}))
+	assert.Equal(t, []string{"В задача", "Б|задача", "Г задача", "А задача"},
+		sortedTitles(t, url.Values{"tag": {tag}, "sort": {"priority"}, "order": {"desc"}}))
+	assert.Equal(t, []string{"А задача", "Б|задача", "В задача", "Г задача"},
+		sortedTitles(t, url.Values{"tag": {tag}, "sort": {"title"}}))
+	assert.Equal(t, []string{"Г задача", "В задача", "А задача", "Б|задача"},
+		sortedTitles(t, url.Values{"tag": {tag}, "sort": {"created"}, "order": {"desc"}}))
+
+	for _, query := range []string{"sort=rank", "order=up"} {
+		_, status, err := requestAs(Token, "api/tasks?"+query, nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, status, query)
+	}
+}
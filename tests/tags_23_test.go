@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func taggedTitles(t *testing.T, query url.Values) []string {
+	ret, status, err := requestAs(Token, "api/tasks?"+query.Encode(), nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status, ret["error"])
+	titles := []string{}
+	tasks, _ := ret["tasks"].([]any)
+	for _, task := range tasks {
+		titles = append(titles, fmt.Sprint(task.(map[string]any)["title"]))
+	}
+	return titles
+}
+
+func tagCounts(t *testing.T) map[string]float64 {
+	ret, status, err := requestAs(Token, "api/tags", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	counts := map[string]float64{}
+	tags, _ := ret["tags"].([]any)
+	for _, tag := range tags {
+		m := tag.(map[string]any)
+		counts[fmt.Sprint(m["name"])] = m["count"].(float64)
+	}
+	return counts
+}
+
+func TestTags(t *testing.T) {
+	//unique tags keep the test independent of the tasks left by the other tests
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	home, work, urgent := "home"+suffix, "work"+suffix, "urgent"+suffix
+
+	_, status, err := requestAs(Token, "api/task", map[string]any{"title": "Без тега", "tags": []string{" "}}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	ret, status, err := requestAs(Token, "api/task", map[string]any{"title": "Помыть окна",
+		"tags": []string{" Home" + suffix, home, urgent}}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status, ret["error"])
+	windows := fmt.Sprint(ret["id"])
+
+	ret, status, err = requestAs(Token, "api/task", map[string]any{"title": "Написать отчёт",
+		"tags": []string{work, urgent}}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status, ret["error"])
+	report := fmt.Sprint(ret["id"])
+
+	ret, _, err = requestAs(Token, "api/task?id="+windows, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []any{home, urgent}, ret["tags"])
+
+	assert.Equal(t, []string{"Помыть окна"}, taggedTitles(t, url.Values{"tag": {home, urgent}}))
+	assert.ElementsMatch(t, []string{"Помыть окна", "Написать отчёт"},
+		taggedTitles(t, url.Values{"tag": {home, work}, "tag_mode": {"or"}}))
+	assert.Empty(t, taggedTitles(t, url.Values{"tag": {home, work}}))
+
+	_, status, err = requestAs(Token, "api/tasks?tag_mode=xor", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	counts := tagCounts(t)
+	assert.Equal(t, float64(2), counts[urgent])
+	assert.Equal(t, float64(1), counts[home])
+
+	//the update without tags keeps them, the empty list removes them
+	ret, status, _ = requestIfMatch(t, "api/task", map[string]any{"id": report, "title": "Написать отчёт за месяц",
+		"date": time.Now().Format("20060102")}, http.MethodPut, taskETag(t, report))
+	assert.Equal(t, http.StatusOK, status, ret["error"])
+	assert.Equal(t, []string{"Написать отчёт за месяц"}, taggedTitles(t, url.Values{"tag": {work}}))
+
+	ret, status, _ = requestIfMatch(t, "api/task", map[string]any{"id": report, "title": "Написать отчёт за месяц",
+		"date": time.Now().Format("20060102"), "tags": []string{}}, http.MethodPut, taskETag(t, report))
+	assert.Equal(t, http.StatusOK, status, ret["error"])
+	assert.Empty(t, taggedTitles(t, url.Values{"tag": {work}}))
+	assert.Equal(t, float64(1), tagCounts(t)[urgent])
+
+	_, status, err = requestAs(Token, "api/task?id="+windows, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	_, ok := tagCounts(t)[home]
+	assert.False(t, ok)
+
+	_, status, err = requestAs(Token, "api/task?id="+report, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+}
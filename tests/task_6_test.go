@@ -77,15 +77,15 @@ func TestEditTask(t *testing.T) {
 		{id, task{"28.01.2024", "Заголовок", "", ""}},
 		{id, task{"20240212", "Заголовок", "", "ooops"}},
 	}
+	etag := taskETag(t, id)
 	for _, v := range tbl {
-		m, err := postJSON("api/task", map[string]any{
+		m, _, _ := requestIfMatch(t, "api/task", map[string]any{
 			"id":      v.id,
 			"date":    v.date,
 			"title":   v.title,
 			"comment": v.comment,
 			"repeat":  v.repeat,
-		}, http.MethodPut)
-		assert.NoError(t, err)
+		}, http.MethodPut, etag)
 
 		var errVal string
 		e, ok := m["error"]
@@ -96,14 +96,13 @@ func TestEditTask(t *testing.T) {
 	}
 
 	updateTask := func(newVals map[string]any) {
-		mupd, err := postJSON("api/task", newVals, http.MethodPut)
-		assert.NoError(t, err)
+		mupd, _, _ := requestIfMatch(t, "api/task", newVals, http.MethodPut, taskETag(t, id))
 
 		e, ok := mupd["error"]
 		assert.False(t, ok && fmt.Sprint(e) != "")
 
 		var task Task
-		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		err := db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
 		assert.NoError(t, err)
 
 		assert.Equal(t, id, strconv.FormatInt(task.ID, 10))
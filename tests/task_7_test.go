@@ -29,8 +29,7 @@ func TestDone(t *testing.T) {
 		title: "Свести баланс",
 	})
 
-	ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
-	assert.NoError(t, err)
+	ret, _, _ := requestIfMatch(t, "api/task/done?id="+id, nil, http.MethodPost, taskETag(t, id))
 	assert.Empty(t, ret)
 	notFoundTask(t, id)
 
@@ -40,12 +39,11 @@ func TestDone(t *testing.T) {
 	})
 
 	for i := 0; i < 3; i++ {
-		ret, err := postJSON("api/task/done?id="+id, nil, http.MethodPost)
-		assert.NoError(t, err)
+		ret, _, _ := requestIfMatch(t, "api/task/done?id="+id, nil, http.MethodPost, taskETag(t, id))
 		assert.Empty(t, ret)
 
 		var task Task
-		err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+		err := db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
 		assert.NoError(t, err)
 		now = now.AddDate(0, 0, 3)
 		assert.Equal(t, task.Date, now.Format(`20060102`))
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeZone(t *testing.T) {
+	//the day in the zone is mostly different from the day of the server
+	const zone = "Pacific/Kiritimati"
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		t.Skip("tzdata isn't available:", err)
+	}
+	now := time.Now().In(loc)
+	if now.Hour() == 0 && now.Minute() == 0 {
+		t.Skip("the overdue time is checked a minute later")
+	}
+	today := now.Format("20060102")
+	//the tasks are found by the unique word, the tags would break the older tests reading the trash
+	word := fmt.Sprint("zone", time.Now().UnixNano())
+
+	for _, task := range []map[string]any{
+		{"title": "Неверное время", "time": "25:00"},
+		{"title": "Неверная зона", "timezone": "Mars/Olympus"},
+	} {
+		_, status, err := requestAs(Token, "api/task", task, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, status, task)
+	}
+
+	ids := map[string]string{}
+	for _, task := range []map[string]any{
+		{"title": "Утренняя зарядка", "time": "0:00"},
+		{"title": "Вечерняя прогулка", "time": "23:59"},
+		{"title": "Весь день"},
+	} {
+		title := task["title"].(string)
+		task["title"], task["date"], task["timezone"] = title+" "+word, today, zone
+		ret, status, err := requestAs(Token, "api/task", task, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, status, ret["error"])
+		ids[title] = fmt.Sprint(ret["id"])
+	}
+
+	ret, _, err := requestAs(Token, "api/task?id="+ids["Утренняя зарядка"], nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, today, ret["date"])
+	assert.Equal(t, "00:00", ret["time"])
+	assert.Equal(t, zone, ret["timezone"])
+
+	//only the passed time of today is overdue, the task without time is overdue the next day
+	assert.Equal(t, []string{"Утренняя зарядка " + word},
+		taggedTitles(t, url.Values{"search": {word}, "overdue": {"true"}}))
+	//the tasks of the day are ordered by time, the task without time goes first
+	assert.Equal(t, []string{"Весь день " + word, "Утренняя зарядка " + word, "Вечерняя прогулка " + word},
+		taggedTitles(t, url.Values{"search": {word}, "sort": {"date"}}))
+
+	_, status, err := requestAs(Token, "api/user", map[string]any{"timezone": "Mars/Olympus"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	_, status, err = requestAs(Token, "api/user", map[string]any{"timezone": zone}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	defer requestAs(Token, "api/user", map[string]any{"timezone": ""}, http.MethodPut)
+
+	ret, _, err = requestAs(Token, "api/user", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, zone, ret["timezone"])
+
+	//the task without the date is planned for today of the user
+	ret, status, err = requestAs(Token, "api/task", map[string]any{"title": "Сегодня у пользователя"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status, ret["error"])
+	id := fmt.Sprint(ret["id"])
+	ret, _, err = requestAs(Token, "api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, today, ret["date"])
+	assert.Nil(t, ret["timezone"])
+
+	for _, id := range append([]string{id}, ids["Утренняя зарядка"], ids["Вечерняя прогулка"], ids["Весь день"]) {
+		_, status, err = requestAs(Token, "api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, status)
+	}
+
+	//the days of the history are the days of the user
+	done := addTask(t, task{date: today, title: "Выполнено сегодня у пользователя"})
+	ret, _, _ = requestIfMatch(t, "api/task/done?id="+done, nil, http.MethodPost, taskETag(t, done))
+	assert.Empty(t, ret)
+	found := false
+	for _, c := range getCompletions(t, "api/history?from="+today+"&to="+today) {
+		found = found || c.TaskId == done
+	}
+	assert.True(t, found)
+}
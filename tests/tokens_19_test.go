@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokens(t *testing.T) {
+	password := os.Getenv("TODO_PASSWORD")
+	if len(password) == 0 {
+		t.Skip("Токены выдаются только с паролем, задайте TODO_PASSWORD серверу и тестам")
+	}
+
+	ret, status, err := requestAs("", "api/signin", map[string]any{"password": password}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	token, _ := ret["token"].(string)
+	refresh, _ := ret["refresh_token"].(string)
+	assert.NotEmpty(t, token)
+	assert.NotEmpty(t, refresh)
+
+	//the refresh token isn't accepted instead of the access one
+	_, status, err = requestAs(refresh, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	ret, status, err = requestAs("", "api/token/refresh", map[string]any{"refresh_token": refresh}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status, ret["error"])
+	newToken, _ := ret["token"].(string)
+	newRefresh, _ := ret["refresh_token"].(string)
+	assert.NotEmpty(t, newToken)
+	assert.NotEqual(t, refresh, newRefresh)
+
+	_, status, err = requestAs(newToken, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	//the refresh token can be used only once
+	_, status, err = requestAs("", "api/token/refresh", map[string]any{"refresh_token": refresh}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	_, status, err = requestAs(newToken, "api/signout", map[string]any{"refresh_token": newRefresh}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	_, status, err = requestAs(newToken, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	_, status, err = requestAs("", "api/token/refresh", map[string]any{"refresh_token": newRefresh}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	//tokens issued before sign out are still valid
+	_, status, err = requestAs(token, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	//only one of the concurrent refreshes with the same token gets the new pair,
+	//the requests are sent by the separate connections at once to overlap on the server
+	for round := 0; round < 20; round++ {
+		ret, status, err = requestAs("", "api/signin", map[string]any{"password": password}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, status)
+		data, _ := json.Marshal(map[string]any{"refresh_token": ret["refresh_token"]})
+		statuses := make([]int, 20)
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := range statuses {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client := &http.Client{Transport: &http.Transport{}}
+				<-start
+				resp, err := client.Post(getURL("api/token/refresh"), "application/json", bytes.NewReader(data))
+				if assert.NoError(t, err) {
+					resp.Body.Close()
+					statuses[i] = resp.StatusCode
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+		refreshed := 0
+		for _, status := range statuses {
+			if status == http.StatusOK {
+				refreshed++
+			} else {
+				assert.Equal(t, http.StatusUnauthorized, status)
+			}
+		}
+		assert.Equal(t, 1, refreshed, statuses)
+	}
+}
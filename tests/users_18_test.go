@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the token of the default user is received on start if the server password is passed via TODO_PASSWORD
+func init() {
+	password := os.Getenv("TODO_PASSWORD")
+	if len(Token) > 0 || len(password) == 0 {
+		return
+	}
+	ret, _, err := requestAs("", "api/signin", map[string]any{"password": password}, http.MethodPost)
+	if err == nil {
+		Token, _ = ret["token"].(string)
+	}
+}
+
+func requestAs(token string, apipath string, values map[string]any, method string) (map[string]any, int, error) {
+	var data []byte
+	if len(values) > 0 {
+		var err error
+		if data, err = json.Marshal(values); err != nil {
+			return nil, 0, err
+		}
+	}
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewBuffer(data))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	var m map[string]any
+	err = json.Unmarshal(body, &m)
+	return m, resp.StatusCode, err
+}
+
+func registerUser(t *testing.T, login string, password string) string {
+	if os.Getenv("TODO_REGISTRATION") != "true" {
+		t.Skip("Регистрация отключена по умолчанию, задайте TODO_REGISTRATION=true серверу и тестам")
+	}
+	ret, status, err := requestAs("", "api/register", map[string]any{"login": login, "password": password}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status, ret["error"])
+
+	ret, status, err = requestAs("", "api/signin", map[string]any{"login": login, "password": password}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status, ret["error"])
+	token, _ := ret["token"].(string)
+	assert.NotEmpty(t, token)
+	return token
+}
+
+func TestUsers(t *testing.T) {
+	if len(os.Getenv("TODO_PASSWORD")) == 0 {
+		t.Skip("Аккаунты доступны только с паролем, задайте TODO_PASSWORD серверу и тестам")
+	}
+
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	if os.Getenv("TODO_REGISTRATION") != "true" {
+		_, status, err := requestAs("", "api/register", map[string]any{"login": "alice" + suffix, "password": "alice-password"}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, status, "Регистрация должна быть отключена по умолчанию")
+	}
+	alice := registerUser(t, "alice"+suffix, "alice-password")
+	bob := registerUser(t, "bob"+suffix, "bob-password")
+
+	ret, status, err := requestAs("", "api/register", map[string]any{"login": "alice" + suffix, "password": "another"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.NotEmpty(t, ret["error"])
+
+	_, status, err = requestAs("", "api/signin", map[string]any{"login": "alice" + suffix, "password": "bob-password"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	ret, status, err = requestAs(alice, "api/task", map[string]any{"title": "Задача Алисы"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status)
+	id := fmt.Sprint(ret["id"])
+
+	ret, status, err = requestAs(alice, "api/task?id="+id, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "Задача Алисы", ret["title"])
+
+	//neither the other user nor the default one see the task
+	for _, token := range []string{bob, Token} {
+		_, status, err = requestAs(token, "api/task?id="+id, nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, status)
+
+		ret, status, err = requestAs(token, "api/tasks", nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, status)
+		tasks, _ := ret["tasks"].([]any)
+		for _, task := range tasks {
+			assert.NotEqual(t, id, task.(map[string]any)["id"])
+		}
+
+		_, status, err = requestAs(token, "api/task?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, status)
+	}
+
+	ret, status, err = requestAs(bob, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(0), ret["total"])
+
+	_, status, err = requestAs("", "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
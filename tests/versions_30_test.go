@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// requestIfMatch sends the request with the If-Match header and returns the ETag of the response
+func requestIfMatch(t *testing.T, apipath string, values map[string]any, method string, ifMatch string) (map[string]any, int, string) {
+	var data []byte
+	if len(values) > 0 {
+		var err error
+		data, err = json.Marshal(values)
+		assert.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewBuffer(data))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if len(ifMatch) > 0 {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return nil, 0, ""
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	return m, resp.StatusCode, resp.Header.Get("ETag")
+}
+
+// taskETag returns the ETag of the task to pass it in If-Match of the update
+func taskETag(t *testing.T, id string) string {
+	_, status, etag := requestIfMatch(t, "api/task?id="+id, nil, http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, status)
+	return etag
+}
+
+func TestVersions(t *testing.T) {
+	today := time.Now().Format("20060102")
+	ret, status, err := requestAs(Token, "api/task", map[string]any{"title": "Составить план", "date": today,
+		"repeat": "d 2"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, status, ret["error"])
+	id := fmt.Sprint(ret["id"])
+	defer requestAs(Token, "api/task?id="+id, nil, http.MethodDelete)
+
+	_, status, first := requestIfMatch(t, "api/task?id="+id, nil, http.MethodGet, "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, first)
+
+	update := map[string]any{"id": id, "title": "Составить план на неделю", "date": today, "repeat": "d 2"}
+	ret, status, _ = requestIfMatch(t, "api/task", update, http.MethodPut, first)
+	assert.Equal(t, http.StatusOK, status, ret["error"])
+	_, _, second := requestIfMatch(t, "api/task?id="+id, nil, http.MethodGet, "")
+	assert.NotEqual(t, first, second)
+
+	//the stale version gets the current copy of the task
+	update["title"] = "Составить план на месяц"
+	ret, status, etag := requestIfMatch(t, "api/task", update, http.MethodPut, first)
+	assert.Equal(t, http.StatusPreconditionFailed, status)
+	assert.Equal(t, "Составить план на неделю", ret["title"])
+	assert.Equal(t, second, etag)
+	_, status, _ = requestIfMatch(t, "api/task/done?id="+id, nil, http.MethodPost, first)
+	assert.Equal(t, http.StatusPreconditionFailed, status)
+
+	results := batchResults(t, map[string]any{"operations": []any{
+		map[string]any{"op": "update", "task": update, "if_match": first},
+	}}, http.StatusPreconditionFailed)
+	if assert.Len(t, results, 1) {
+		assert.NotEmpty(t, results[0]["error"])
+	}
+
+	//the version is required to change the task unless the check is turned off
+	if os.Getenv("TODO_REQUIRE_IF_MATCH") != "false" {
+		_, status, _ = requestIfMatch(t, "api/task", update, http.MethodPut, "")
+		assert.Equal(t, http.StatusPreconditionRequired, status)
+		_, status, _ = requestIfMatch(t, "api/task/done?id="+id, nil, http.MethodPost, "")
+		assert.Equal(t, http.StatusPreconditionRequired, status)
+		batchResults(t, map[string]any{"operations": []any{
+			map[string]any{"op": "done", "id": id},
+		}}, http.StatusPreconditionRequired)
+	}
+
+	ret, status, _ = requestIfMatch(t, "api/task/done?id="+id, nil, http.MethodPost, "W/"+second)
+	assert.Equal(t, http.StatusOK, status, ret["error"])
+	ret, _, third := requestIfMatch(t, "api/task?id="+id, nil, http.MethodGet, "")
+	assert.Equal(t, time.Now().AddDate(0, 0, 2).Format("20060102"), ret["date"])
+	assert.NotEqual(t, second, third)
+
+	ret, status, _ = requestIfMatch(t, "api/task", update, http.MethodPut, "*")
+	assert.Equal(t, http.StatusOK, status, ret["error"])
+}